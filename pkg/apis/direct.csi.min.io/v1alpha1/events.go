@@ -0,0 +1,52 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package v1alpha1
+
+// EventSink is notified of every step taken on a volume's storage, e.g.
+// to keep an audit log of destructive actions or update resources.
+// Implementations must not block.
+type EventSink interface {
+	// OnClaim is called when storage is carved out for a volume
+	OnClaim(volumeID, path string)
+	// OnFormat is called when a filesystem is created for a volume
+	OnFormat(volumeID, device, fsType string)
+	// OnMount is called when a volume is mounted or linked at target
+	OnMount(volumeID, source, target string)
+	// OnRelease is called when a volume's storage and data are removed
+	OnRelease(volumeID, path string)
+	// OnError is called when step fails for a volume
+	OnError(volumeID, step string, err error)
+}
+
+type nopEventSink struct{}
+
+func (nopEventSink) OnClaim(volumeID, path string)            {}
+func (nopEventSink) OnFormat(volumeID, device, fsType string) {}
+func (nopEventSink) OnMount(volumeID, source, target string)  {}
+func (nopEventSink) OnRelease(volumeID, path string)          {}
+func (nopEventSink) OnError(volumeID, step string, err error) {}
+
+var events EventSink = nopEventSink{}
+
+// SetEventSink replaces the default no-op sink. It is meant to be called
+// once during startup, before any volume is served.
+func SetEventSink(sink EventSink) {
+	if sink == nil {
+		sink = nopEventSink{}
+	}
+	events = sink
+}
@@ -20,9 +20,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/golang/glog"
+	"github.com/minio/direct-csi/pkg/sys"
 )
 
 var (
@@ -33,34 +35,75 @@ var (
 type vFactory struct {
 	Paths        []string
 	LastAssigned int
+	Retention    sys.RetentionPolicy
+	// ClassRules pick the drive class, and with it the deletion policy,
+	// of the drive a volume is removed from
+	ClassRules []sys.ClassRule
 }
 
 func InitializeFactory(paths []string) {
 	vf.Paths = paths
 	vf.LastAssigned = -1
+	vf.Retention = sys.RetentionPolicy{Mode: sys.RetentionImmediate}
 }
 
 func Provision(volumeID string) (string, error) {
+	// the volume directory must be a direct child of a base path, or a
+	// later Unprovision would remove more than the volume
+	if volumeID == "" || volumeID == "." || volumeID == ".." || strings.Contains(volumeID, "/") {
+		return "", fmt.Errorf("invalid volume id %q", volumeID)
+	}
+
 	provisionerLock.Lock()
 	defer provisionerLock.Unlock()
 
 	if len(vf.Paths) == 0 {
 		return "", fmt.Errorf("no base paths provided for direct CSI")
 	}
+
+	// a retried stage request must get back the directory provisioned the
+	// first time around instead of a fresh one on the next drive
+	for _, p := range vf.Paths {
+		dir := filepath.Join(p, volumeID)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			glog.V(5).Infof("[%s] reusing provisioned directory %s", volumeID, dir)
+			return dir, nil
+		}
+	}
+
 	next := vf.LastAssigned + 1
 	next = next % len(vf.Paths)
 
 	nextPath := vf.Paths[next]
 	glog.V(15).Infof("[%s] using direct storage: BasePaths[%d] = %s", volumeID, next, nextPath)
 
-	if err := os.MkdirAll(filepath.Join(nextPath, volumeID), 0755); err != nil {
+	dir := filepath.Join(nextPath, volumeID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		events.OnError(volumeID, "claim", err)
 		return "", err
 	}
 	vf.LastAssigned = next
+	events.OnClaim(volumeID, dir)
 
-	return filepath.Join(nextPath, volumeID), nil
+	return dir, nil
 }
 
 func Unprovision(path string) error {
-	return os.RemoveAll(path)
+	if path == "" {
+		return nil
+	}
+	volumeID := filepath.Base(path)
+	// volumes are direct children of a base path on their drive
+	basePath := filepath.Dir(path)
+	class, err := sys.DriveClassOf(basePath, vf.ClassRules)
+	if err != nil {
+		events.OnError(volumeID, "release", err)
+		return err
+	}
+	if err := sys.RetireVolume(path, basePath, vf.Retention, sys.DeletionPolicyFor(class)); err != nil {
+		events.OnError(volumeID, "release", err)
+		return err
+	}
+	events.OnRelease(volumeID, path)
+	return nil
 }
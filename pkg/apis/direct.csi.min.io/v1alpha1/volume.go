@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"github.com/golang/glog"
+	"github.com/minio/direct-csi/pkg/sys"
 	"github.com/pborman/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -205,8 +206,10 @@ func (v *Volume) Bind(ctx context.Context, targetPath string, readOnly bool, vol
 	}
 
 	if err := os.Symlink(v.VolumeSource.VolumeSourcePath, targetPath); err != nil {
+		events.OnError(v.VolumeID, "bind", err)
 		return status.Error(codes.Internal, err.Error())
 	}
+	events.OnMount(v.VolumeID, v.VolumeSource.VolumeSourcePath, targetPath)
 
 	access := AccessRW
 	if readOnly {
@@ -264,17 +267,15 @@ func (v *Volume) Mount(ctx context.Context, targetPath string, fsType string, mo
 		}
 	}
 
-	options := []string{"bind"}
+	flags := sys.ParseMountFlags(append([]string{"bind"}, mountFlags...))
 	access := AccessRW
 
+	// ro goes last so that an rw among the requested flags cannot undo it
 	if readOnly {
 		access = AccessRO
-		options = append(options, "ro")
-	}
-
-	for _, f := range mountFlags {
-		options = append(options, f)
+		flags.Parse([]string{"ro"})
 	}
+	options := flags.Options()
 
 	mounter := mount.New("")
 	notMount, err := mount.IsNotMountPoint(mounter, targetPath)
@@ -294,8 +295,10 @@ func (v *Volume) Mount(ctx context.Context, targetPath string, fsType string, mo
 	}
 
 	if err := mounter.Mount(v.VolumeSource.VolumeSourcePath, targetPath, fsType, options); err != nil {
+		events.OnError(v.VolumeID, "mount", err)
 		return err
 	}
+	events.OnMount(v.VolumeID, v.VolumeSource.VolumeSourcePath, targetPath)
 
 	v.MountAccess = append(v.MountAccess, MountAccessType{
 		FsType: FsType(fsType),
@@ -362,8 +365,19 @@ func (v *Volume) StageVolume(ctx context.Context, volumeID, stagePath string) er
 		return err
 	}
 
-	if err := mount.New("").Mount(dir, stagePath, "", []string{"bind"}); err != nil {
-		return err
+	mounter := mount.New("")
+	notMount, err := mount.IsNotMountPoint(mounter, stagePath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "error checking path %s for mount: %s", stagePath, err)
+	}
+	if notMount {
+		if err := mounter.Mount(dir, stagePath, "", []string{"bind"}); err != nil {
+			events.OnError(volumeID, "stage", err)
+			return err
+		}
+		events.OnMount(volumeID, dir, stagePath)
+	} else {
+		glog.V(5).Infof("Skipping bind-mounting %s: already mounted", stagePath)
 	}
 
 	v.VolumeSource = VolumeSource{
@@ -18,11 +18,16 @@ package node
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/golang/glog"
 	"github.com/minio/direct-csi/pkg/apis/direct.csi.min.io/v1alpha1"
+	"github.com/minio/direct-csi/pkg/sys"
 	"github.com/minio/direct-csi/pkg/topology"
+	"golang.org/x/sys/unix"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -30,8 +35,17 @@ import (
 
 const MaxVolumes = 10000
 
+// VolumeStatsTTL is how long the usage of a volume is reused before its
+// directory is walked again
+const VolumeStatsTTL = time.Minute
+
+// TrashSweepInterval is how often the trash of each base path is checked
+// for volumes whose grace period has passed
+const TrashSweepInterval = 10 * time.Minute
+
 func NewNodeServer(identity, nodeID, rack, zone, region string, basePaths []string) (*NodeServer, error) {
 	v1alpha1.VolumeClient(basePaths)
+	go sys.RunTrashSweeper(context.Background(), basePaths, TrashSweepInterval)
 	return &NodeServer{
 		NodeID:    nodeID,
 		Identity:  identity,
@@ -39,6 +53,7 @@ func NewNodeServer(identity, nodeID, rack, zone, region string, basePaths []stri
 		Zone:      zone,
 		Region:    region,
 		BasePaths: basePaths,
+		usage:     sys.NewDirectorySizeCache(VolumeStatsTTL),
 	}, nil
 }
 
@@ -49,6 +64,8 @@ type NodeServer struct {
 	Zone      string
 	Region    string
 	BasePaths []string
+
+	usage *sys.DirectorySizeCache
 }
 
 func (n *NodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
@@ -230,7 +247,83 @@ func (n *NodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstage
 }
 
 func (ns *NodeServer) NodeGetVolumeStats(ctx context.Context, in *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "unimplemented")
+	vID := in.GetVolumeId()
+	volumePath := in.GetVolumePath()
+
+	if vID == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume ID missing in request")
+	}
+	if volumePath == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume path missing in request")
+	}
+
+	// block access volumes are symlinks to their source, which is either
+	// a device or a volume directory
+	var lst unix.Stat_t
+	if err := unix.Lstat(volumePath, &lst); err != nil {
+		if os.IsNotExist(err) {
+			return nil, status.Errorf(codes.NotFound, "volume path %s not found", volumePath)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if lst.Mode&unix.S_IFMT == unix.S_IFLNK {
+		target, err := filepath.EvalSymlinks(volumePath)
+		if err != nil {
+			return nil, status.Errorf(codes.NotFound, "volume path %s not found: %v", volumePath, err)
+		}
+		var tst unix.Stat_t
+		if err := unix.Stat(target, &tst); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if tst.Mode&unix.S_IFMT == unix.S_IFBLK {
+			size, err := sys.DeviceSize(target)
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			// usage of a raw device is up to whatever uses it
+			return &csi.NodeGetVolumeStatsResponse{
+				Usage: []*csi.VolumeUsage{
+					{
+						Unit:  csi.VolumeUsage_BYTES,
+						Total: int64(size),
+					},
+				},
+			}, nil
+		}
+		volumePath = target
+	}
+
+	// volumes are directories on a shared drive, so statfs only gives the
+	// free space left; usage has to come from walking the volume itself
+	var st unix.Statfs_t
+	if err := unix.Statfs(volumePath, &st); err != nil {
+		if os.IsNotExist(err) {
+			return nil, status.Errorf(codes.NotFound, "volume path %s not found", volumePath)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	used, inodesUsed, err := ns.usage.DirectorySize(ctx, volumePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "could not compute usage of %s: %v", volumePath, err)
+	}
+	available := st.Bavail * uint64(st.Bsize)
+
+	return &csi.NodeGetVolumeStatsResponse{
+		Usage: []*csi.VolumeUsage{
+			{
+				Unit:      csi.VolumeUsage_BYTES,
+				Used:      int64(used),
+				Available: int64(available),
+				Total:     int64(used + available),
+			},
+			{
+				Unit:      csi.VolumeUsage_INODES,
+				Used:      int64(inodesUsed),
+				Available: int64(st.Ffree),
+				Total:     int64(inodesUsed + st.Ffree),
+			},
+		},
+	}, nil
 }
 
 func (ns *NodeServer) NodeExpandVolume(ctx context.Context, in *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
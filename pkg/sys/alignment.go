@@ -0,0 +1,94 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+	"os"
+)
+
+type AlignmentIssue struct {
+	Partition int
+	// Start and RecommendedStart are byte offsets
+	Start            uint64
+	RecommendedStart uint64
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// partitionAlignment is what partitions should start on: 1MiB, as
+// partitioning tools default to, widened to a multiple of the optimal
+// I/O size of RAID devices
+func partitionAlignment(optimalIO uint64) uint64 {
+	align := MiB
+	if optimalIO != 0 && align%optimalIO != 0 {
+		align = align / gcd(align, optimalIO) * optimalIO
+	}
+	return align
+}
+
+// CheckPartitionAlignment reads the partition table of the disk holding
+// devName and returns the partitions whose start is not a multiple of the
+// physical block size or, on RAID devices, of the optimal I/O size, along
+// with where they should start instead. It only reads from the disk.
+func CheckPartitionAlignment(devName string) ([]AlignmentIssue, error) {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return nil, err
+	}
+	logical, physical, _, err := SectorType(disk)
+	if err != nil {
+		return nil, err
+	}
+	optimalIO, err := readQueueUint(disk, "optimal_io_size")
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := openBlockFile(disk, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	parts, err := readPartitionTable(f, int64(logical))
+	if err != nil {
+		return nil, fmt.Errorf("could not read partition table of %s: %v", disk, err)
+	}
+
+	align := partitionAlignment(optimalIO)
+	issues := []AlignmentIssue{}
+	for _, p := range parts {
+		if p.extended {
+			continue
+		}
+		start := uint64(p.start)
+		if start%physical == 0 && (optimalIO == 0 || start%optimalIO == 0) {
+			continue
+		}
+		issues = append(issues, AlignmentIssue{
+			Partition:        p.number,
+			Start:            start,
+			RecommendedStart: (start + align - 1) / align * align,
+		})
+	}
+	return issues, nil
+}
@@ -0,0 +1,104 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const bcacheSuperblockOffset = 4096
+
+var bcacheMagic = [16]byte{
+	0xc6, 0x85, 0x73, 0xf6, 0x4e, 0x1a, 0x45, 0xca,
+	0x82, 0x65, 0xf5, 0x7f, 0x48, 0xba, 0x6d, 0x81,
+}
+
+// superblock versions from <linux/bcache.h>
+const (
+	bcacheSBVersionCdev             = 0
+	bcacheSBVersionBdev             = 1
+	bcacheSBVersionCdevWithUUID     = 3
+	bcacheSBVersionBdevWithOffset   = 4
+	bcacheSBVersionCdevWithFeatures = 5
+	bcacheSBVersionBdevWithFeatures = 6
+)
+
+var ErrNotBcache = errors.New("not a bcache device")
+
+// leading part of struct cache_sb
+type bcacheSuperblock struct {
+	Csum    uint64
+	Offset  uint64
+	Version uint64
+	Magic   [16]byte
+	UUID    [16]byte
+	SetUUID [16]byte
+	Label   [32]byte
+}
+
+type BcacheInfo struct {
+	// IsCache is set for caching devices and unset for backing devices
+	IsCache bool
+	UUID    string
+	SetUUID string
+	Label   string
+}
+
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// ProbeBcache reads the bcache superblock of devName, returning
+// ErrNotBcache if it is not a bcache cache or backing device
+func ProbeBcache(devName string) (*BcacheInfo, error) {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sb := bcacheSuperblock{}
+	r := io.NewSectionReader(f, bcacheSuperblockOffset, int64(binary.Size(sb)))
+	if err := binary.Read(r, binary.LittleEndian, &sb); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNotBcache
+		}
+		return nil, err
+	}
+	if sb.Magic != bcacheMagic {
+		return nil, ErrNotBcache
+	}
+
+	info := &BcacheInfo{
+		UUID:    formatUUID(sb.UUID),
+		SetUUID: formatUUID(sb.SetUUID),
+		Label:   string(bytes.TrimRight(sb.Label[:], "\x00")),
+	}
+	switch sb.Version {
+	case bcacheSBVersionCdev, bcacheSBVersionCdevWithUUID, bcacheSBVersionCdevWithFeatures:
+		info.IsCache = true
+	case bcacheSBVersionBdev, bcacheSBVersionBdevWithOffset, bcacheSBVersionBdevWithFeatures:
+	default:
+		return nil, fmt.Errorf("unknown bcache superblock version %d on %s", sb.Version, devName)
+	}
+	return info, nil
+}
@@ -0,0 +1,257 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+var ErrProcfsUnavailable = errors.New("procfs is not mounted")
+
+// device nodes are also looked up by their bare name in these
+// directories, so that by-id and mapper names need no prefix
+var deviceLinkDirs = []string{
+	filepath.Join("disk", "by-id"),
+	filepath.Join("disk", "by-path"),
+	"mapper",
+}
+
+// getBlockFile returns the device node path of devName, which may be a
+// kernel name such as sda, nvme0n1p1, mmcblk0p2 or dm-0, a path below
+// /dev such as /dev/mapper/vg-lv or /dev/disk/by-id/wwn-0x..., or the
+// bare name of a by-id, by-path or mapper link
+func getBlockFile(devName string) string {
+	name := strings.TrimPrefix(devName, "/dev/")
+	if filepath.IsAbs(name) {
+		if rel, err := filepath.Rel(DevRoot, name); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+	}
+	path := filepath.Join(DevRoot, name)
+	if _, err := os.Lstat(path); err == nil || strings.Contains(name, "/") {
+		return path
+	}
+	for _, dir := range deviceLinkDirs {
+		p := filepath.Join(DevRoot, dir, name)
+		if _, err := os.Lstat(p); err == nil {
+			return p
+		}
+	}
+	return path
+}
+
+// EnsureDeviceNode creates the device node of devName from the major and
+// minor numbers in sysfs if udev has not created it yet, and returns its
+// path. Nodes are never created for devices unknown to sysfs.
+func EnsureDeviceNode(devName string) (string, error) {
+	path := getBlockFile(devName)
+	if _, err := os.Stat(path); err == nil || !os.IsNotExist(err) {
+		return path, err
+	}
+
+	sysPath, err := sysfsBlockPath(devName)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(sysPath, "dev"))
+	if err != nil {
+		return "", err
+	}
+	var major, minor uint32
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(b)), "%d:%d", &major, &minor); err != nil {
+		return "", fmt.Errorf("could not parse device number of %s: %v", devName, err)
+	}
+
+	glog.V(5).Infof("creating missing device node %s (%d:%d)", path, major, minor)
+	if err := unix.Mknod(path, unix.S_IFBLK|0660, int(unix.Mkdev(major, minor))); err != nil && !os.IsExist(err) {
+		return "", fmt.Errorf("could not create device node %s: %v", path, err)
+	}
+	return path, nil
+}
+
+// openBlockFile opens the device node of devName, creating it first if
+// the kernel knows the device but its node has not appeared yet
+func openBlockFile(devName string, flag int) (*os.File, error) {
+	f, err := os.OpenFile(getBlockFile(devName), flag, 0)
+	if err == nil || !os.IsNotExist(err) {
+		return f, err
+	}
+	path, err := EnsureDeviceNode(devName)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, flag, 0)
+}
+
+func blockDeviceRdev(path string) (uint64, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return 0, fmt.Errorf("%s is not a block device", path)
+	}
+	return st.Rdev, nil
+}
+
+// IsDeviceBusy reports whether any other process holds the device open,
+// along with the PIDs of the holders
+func IsDeviceBusy(devName string) (bool, []int, error) {
+	rdev, err := blockDeviceRdev(getBlockFile(devName))
+	if err != nil {
+		return false, nil, err
+	}
+
+	procs, err := ioutil.ReadDir(ProcRoot)
+	if err != nil {
+		return false, nil, fmt.Errorf("%w: %v", ErrProcfsUnavailable, err)
+	}
+
+	self := os.Getpid()
+	pids := []int{}
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		fdDir := filepath.Join(ProcRoot, p.Name(), "fd")
+		fds, err := ioutil.ReadDir(fdDir)
+		if err != nil {
+			// the process exited or we lack permission to inspect it
+			continue
+		}
+		for _, fd := range fds {
+			var st unix.Stat_t
+			if err := unix.Stat(filepath.Join(fdDir, fd.Name()), &st); err != nil {
+				continue
+			}
+			if st.Mode&unix.S_IFMT == unix.S_IFBLK && st.Rdev == rdev {
+				pids = append(pids, pid)
+				break
+			}
+		}
+	}
+	return len(pids) != 0, pids, nil
+}
+
+func ioctlBlockDevice(devName string, req uintptr, arg unsafe.Pointer) error {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ioctl(f.Fd(), req, arg)
+}
+
+// DeviceSizeIoctl returns the size of devName in bytes straight from the
+// kernel, which unlike sysfs is never stale after a partition table change
+func DeviceSizeIoctl(devName string) (uint64, error) {
+	var size uint64
+	if err := ioctlBlockDevice(devName, unix.BLKGETSIZE64, unsafe.Pointer(&size)); err != nil {
+		return 0, fmt.Errorf("could not get size of %s: %v", devName, err)
+	}
+	return size, nil
+}
+
+// SectorSizeIoctl returns the logical sector size of devName
+func SectorSizeIoctl(devName string) (uint64, error) {
+	var size int32
+	if err := ioctlBlockDevice(devName, unix.BLKSSZGET, unsafe.Pointer(&size)); err != nil {
+		return 0, fmt.Errorf("could not get sector size of %s: %v", devName, err)
+	}
+	return uint64(size), nil
+}
+
+// DeviceSize returns the size of devName, preferring the ioctl and
+// falling back to sysfs when the device cannot be opened
+func DeviceSize(devName string) (uint64, error) {
+	size, err := DeviceSizeIoctl(devName)
+	if err == nil {
+		return size, nil
+	}
+	glog.V(5).Infof("falling back to sysfs for size of %s: %v", devName, err)
+	return ReadDeviceSize(devName)
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IsDeviceBlank reports whether devName looks unused, by checking that its
+// first and last MiB, where partition tables and superblocks live, plus
+// sampleCount random regions in between are all zeros
+func IsDeviceBlank(devName string, sampleCount int) (bool, error) {
+	const (
+		edgeSize   = 1 << 20
+		sampleSize = 64 << 10
+	)
+
+	size, err := DeviceSize(devName)
+	if err != nil {
+		return false, err
+	}
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	isBlank := func(offset, length int64) (bool, error) {
+		buf := make([]byte, length)
+		if _, err := f.ReadAt(buf, offset); err != nil {
+			return false, fmt.Errorf("could not read %s at %d: %v", devName, offset, err)
+		}
+		return isZero(buf), nil
+	}
+
+	if size <= 2*edgeSize {
+		return isBlank(0, int64(size))
+	}
+
+	offsets := []int64{0, int64(size) - edgeSize}
+	lengths := []int64{edgeSize, edgeSize}
+	middle := int64(size) - 2*edgeSize - sampleSize
+	for i := 0; i < sampleCount && middle > 0; i++ {
+		offset := edgeSize + rand.Int63n(middle)
+		offsets = append(offsets, offset-offset%4096)
+		lengths = append(lengths, sampleSize)
+	}
+
+	for i := range offsets {
+		blank, err := isBlank(offsets[i], lengths[i])
+		if err != nil || !blank {
+			return false, err
+		}
+	}
+	return true, nil
+}
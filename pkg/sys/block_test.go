@@ -0,0 +1,99 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeDevRoot points DevRoot at a directory holding empty files for
+// device nodes and symlinks for the udev links
+func fakeDevRoot(t *testing.T, nodes []string, links map[string]string) string {
+	dir, err := ioutil.TempDir("", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDevRoot := DevRoot
+	DevRoot = dir
+	t.Cleanup(func() {
+		DevRoot = oldDevRoot
+		os.RemoveAll(dir)
+	})
+
+	for _, n := range nodes {
+		if err := ioutil.WriteFile(filepath.Join(dir, n), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range links {
+		p := filepath.Join(dir, link)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGetBlockFile(t *testing.T) {
+	dev := fakeDevRoot(t,
+		[]string{"sda", "sda1", "nvme0n1", "nvme0n1p1", "mmcblk0", "mmcblk0p2", "dm-0"},
+		map[string]string{
+			"mapper/vg0-lv0":                  "../dm-0",
+			"disk/by-id/wwn-0x5000c500a1b2c3": "../../sda",
+			"disk/by-id/nvme-eui.0025388b":    "../../nvme0n1p1",
+			"disk/by-path/pci-0000:00:1f.2":   "../../mmcblk0p2",
+		})
+
+	testCases := []struct {
+		devName   string
+		path      string
+		blockName string
+	}{
+		{"sda", "sda", "sda"},
+		{"/dev/sda1", "sda1", "sda1"},
+		{"nvme0n1", "nvme0n1", "nvme0n1"},
+		{"nvme0n1p1", "nvme0n1p1", "nvme0n1p1"},
+		{"mmcblk0p2", "mmcblk0p2", "mmcblk0p2"},
+		{"dm-0", "dm-0", "dm-0"},
+		{"vg0-lv0", "mapper/vg0-lv0", "dm-0"},
+		{"/dev/mapper/vg0-lv0", "mapper/vg0-lv0", "dm-0"},
+		{"mapper/vg0-lv0", "mapper/vg0-lv0", "dm-0"},
+		{"wwn-0x5000c500a1b2c3", "disk/by-id/wwn-0x5000c500a1b2c3", "sda"},
+		{"/dev/disk/by-id/nvme-eui.0025388b", "disk/by-id/nvme-eui.0025388b", "nvme0n1p1"},
+		{"pci-0000:00:1f.2", "disk/by-path/pci-0000:00:1f.2", "mmcblk0p2"},
+		{filepath.Join(dev, "sda"), "sda", "sda"},
+		// names unknown to /dev are kept as they are
+		{"sdb", "sdb", "sdb"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.devName, func(t *testing.T) {
+			if path := getBlockFile(testCase.devName); path != filepath.Join(dev, testCase.path) {
+				t.Errorf("expected path %s, got %s", filepath.Join(dev, testCase.path), path)
+			}
+			if name := blockName(testCase.devName); name != testCase.blockName {
+				t.Errorf("expected kernel name %s, got %s", testCase.blockName, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+)
+
+const (
+	btrfsIoctlMagic = 0x94
+	btrfsNameMax    = 4087
+
+	btrfsQgroupLimitMaxRfer = 1 << 0
+)
+
+// struct btrfs_ioctl_vol_args
+type btrfsVolArgs struct {
+	fd   int64
+	name [btrfsNameMax + 1]byte
+}
+
+// struct btrfs_ioctl_qgroup_limit_args
+type btrfsQgroupLimitArgs struct {
+	qgroupID uint64
+	flags    uint64
+	maxRfer  uint64
+	maxExcl  uint64
+	rsvRfer  uint64
+	rsvExcl  uint64
+}
+
+var (
+	btrfsIocSnapCreate   = iocIOW(btrfsIoctlMagic, 1, unsafe.Sizeof(btrfsVolArgs{}))
+	btrfsIocSubvolCreate = iocIOW(btrfsIoctlMagic, 14, unsafe.Sizeof(btrfsVolArgs{}))
+	btrfsIocSnapDestroy  = iocIOW(btrfsIoctlMagic, 15, unsafe.Sizeof(btrfsVolArgs{}))
+	btrfsIocQgroupLimit  = iocIOR(btrfsIoctlMagic, 43, unsafe.Sizeof(btrfsQgroupLimitArgs{}))
+)
+
+func newBtrfsVolArgs(name string) (*btrfsVolArgs, error) {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid subvolume name %q", name)
+	}
+	if len(name) > btrfsNameMax {
+		return nil, fmt.Errorf("subvolume name %q exceeds %d bytes", name, btrfsNameMax)
+	}
+	args := &btrfsVolArgs{}
+	copy(args.name[:], name)
+	return args, nil
+}
+
+func btrfsDirIoctl(dir string, req uintptr, args *btrfsVolArgs) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return ioctl(d.Fd(), req, unsafe.Pointer(args))
+}
+
+// CreateBtrfsSubvolume creates the subvolume name under parentMount and
+// returns its path
+func CreateBtrfsSubvolume(parentMount, name string) (string, error) {
+	args, err := newBtrfsVolArgs(name)
+	if err != nil {
+		return "", err
+	}
+	if err := btrfsDirIoctl(parentMount, btrfsIocSubvolCreate, args); err != nil {
+		return "", fmt.Errorf("could not create subvolume %s in %s: %v", name, parentMount, err)
+	}
+	return filepath.Join(parentMount, name), nil
+}
+
+// DeleteBtrfsSubvolume deletes the subvolume name under parentMount
+func DeleteBtrfsSubvolume(parentMount, name string) error {
+	args, err := newBtrfsVolArgs(name)
+	if err != nil {
+		return err
+	}
+	if err := btrfsDirIoctl(parentMount, btrfsIocSnapDestroy, args); err != nil {
+		return fmt.Errorf("could not delete subvolume %s in %s: %v", name, parentMount, err)
+	}
+	return nil
+}
+
+// SnapshotBtrfsSubvolume creates a writable snapshot of the subvolume at
+// srcPath as name under parentMount and returns the snapshot path
+func SnapshotBtrfsSubvolume(srcPath, parentMount, name string) (string, error) {
+	args, err := newBtrfsVolArgs(name)
+	if err != nil {
+		return "", err
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	args.fd = int64(src.Fd())
+	if err := btrfsDirIoctl(parentMount, btrfsIocSnapCreate, args); err != nil {
+		return "", fmt.Errorf("could not snapshot %s to %s in %s: %v", srcPath, name, parentMount, err)
+	}
+	return filepath.Join(parentMount, name), nil
+}
+
+// SetBtrfsSubvolumeLimit caps the referenced bytes of the subvolume at
+// path. Quotas must already be enabled on the filesystem.
+func SetBtrfsSubvolumeLimit(path string, maxBytes uint64) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// a zero qgroup id makes the kernel use the subvolume of the fd
+	args := &btrfsQgroupLimitArgs{
+		flags:   btrfsQgroupLimitMaxRfer,
+		maxRfer: maxBytes,
+	}
+	if err := ioctl(d.Fd(), btrfsIocQgroupLimit, unsafe.Pointer(args)); err != nil {
+		return fmt.Errorf("could not set qgroup limit on %s: %v", path, err)
+	}
+	return nil
+}
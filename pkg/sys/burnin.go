@@ -0,0 +1,160 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+type BurnInOptions struct {
+	// Samples is the number of regions read across the device
+	Samples int
+	// ReadSize is the number of bytes read per sample
+	ReadSize int
+	// SlowThreshold is the latency above which a read counts as slow
+	SlowThreshold time.Duration
+	// MaxDuration bounds the sweep; samples not read by then are skipped
+	MaxDuration time.Duration
+	SkipSMART   bool
+}
+
+var DefaultBurnInOptions = BurnInOptions{
+	Samples:       1024,
+	ReadSize:      1 << 20,
+	SlowThreshold: 500 * time.Millisecond,
+	MaxDuration:   5 * time.Minute,
+}
+
+type BurnInResult struct {
+	SamplesRead int
+	MaxLatency  time.Duration
+	// SlowReads and ReadErrors hold the byte offsets of affected samples
+	SlowReads  []uint64
+	ReadErrors []uint64
+	// SMARTChecked is unset if smartctl is missing or the drive does not
+	// support SMART
+	SMARTChecked bool
+	SMARTFailing bool
+	SMARTOutput  string
+}
+
+// Healthy reports whether the drive passed every check that was run
+func (r *BurnInResult) Healthy() bool {
+	return len(r.SlowReads) == 0 && len(r.ReadErrors) == 0 && !r.SMARTFailing
+}
+
+// BurnInCheck reads samples scattered across devName, timing each read,
+// and checks the drive's SMART health. It never writes to the device.
+func BurnInCheck(devName string, opts BurnInOptions) (*BurnInResult, error) {
+	if opts.Samples <= 0 || opts.ReadSize <= 0 {
+		return nil, fmt.Errorf("burn-in needs a positive sample count and read size")
+	}
+
+	size, err := DeviceSize(devName)
+	if err != nil {
+		return nil, err
+	}
+	if size < uint64(opts.ReadSize) {
+		return nil, fmt.Errorf("%s is smaller than the burn-in read size", devName)
+	}
+
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := &BurnInResult{}
+	buf := make([]byte, opts.ReadSize)
+	stride := (size - uint64(opts.ReadSize)) / uint64(opts.Samples)
+	start := time.Now()
+	for i := 0; i < opts.Samples; i++ {
+		if opts.MaxDuration > 0 && time.Since(start) > opts.MaxDuration {
+			glog.V(5).Infof("burn-in of %s stopped after %d samples", devName, i)
+			break
+		}
+
+		// jitter within each stride so repeated runs cover more of the drive
+		offset := uint64(i) * stride
+		if stride > 0 {
+			offset += uint64(rand.Int63n(int64(stride)))
+		}
+		offset -= offset % 4096
+
+		// drop cached pages so the read really hits the media
+		if err := unix.Fadvise(int(f.Fd()), int64(offset), int64(opts.ReadSize), unix.FADV_DONTNEED); err != nil {
+			return nil, fmt.Errorf("could not drop cached pages of %s: %v", devName, err)
+		}
+
+		t := time.Now()
+		_, err := f.ReadAt(buf, int64(offset))
+		latency := time.Since(t)
+
+		result.SamplesRead++
+		if latency > result.MaxLatency {
+			result.MaxLatency = latency
+		}
+		if err != nil {
+			glog.Errorf("burn-in read of %s at %d failed: %v", devName, offset, err)
+			result.ReadErrors = append(result.ReadErrors, offset)
+			continue
+		}
+		if latency > opts.SlowThreshold {
+			result.SlowReads = append(result.SlowReads, offset)
+		}
+	}
+
+	if !opts.SkipSMART {
+		checkSMART(devName, result)
+	}
+	return result, nil
+}
+
+// smartctl exit status bits, see smartctl(8)
+const (
+	smartctlCommandErrors = 0x07
+	smartctlDiskFailing   = 0x08
+	smartctlPrefailed     = 0x10
+)
+
+func checkSMART(devName string, result *BurnInResult) {
+	out, _, err := run(context.Background(), "smartctl", "-H", getBlockFile(devName))
+	result.SMARTOutput = string(out)
+
+	status := 0
+	if err != nil {
+		code, ok := exitCode(err)
+		if !ok {
+			glog.V(5).Infof("could not run smartctl on %s: %v", devName, err)
+			return
+		}
+		status = code
+	}
+	if status&smartctlCommandErrors != 0 {
+		glog.V(5).Infof("smart health unavailable for %s: exit status %d", devName, status)
+		return
+	}
+	result.SMARTChecked = true
+	result.SMARTFailing = status&(smartctlDiskFailing|smartctlPrefailed) != 0
+}
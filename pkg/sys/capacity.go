@@ -0,0 +1,126 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultCapacityTolerance covers what sizes of the same device differ by
+// between sysfs, BLKGETSIZE64 and the superblocks: sector rounding,
+// alignment and reserved blocks
+const DefaultCapacityTolerance = 4 * MiB
+
+// CapacityApproxEqual reports whether a and b differ by at most tolerance
+func CapacityApproxEqual(a, b, tolerance uint64) bool {
+	if a > b {
+		return a-b <= tolerance
+	}
+	return b-a <= tolerance
+}
+
+type UsageSample struct {
+	Time     time.Time
+	Used     uint64
+	Capacity uint64
+}
+
+// GrowthRate fits a line through the usage samples and returns the growth
+// in bytes per second along with when the volume is projected to be full,
+// going by the capacity of the latest sample. The ETA is the zero time
+// when usage is flat or declining, or when it is too far out for a
+// time.Duration.
+func GrowthRate(samples []UsageSample) (float64, time.Time, error) {
+	if len(samples) < 2 {
+		return 0, time.Time{}, fmt.Errorf("need at least 2 usage samples, got %d", len(samples))
+	}
+
+	origin := samples[0].Time
+	latest := samples[0]
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.Time.Sub(origin).Seconds()
+		y := float64(s.Used)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+		if s.Time.After(latest.Time) {
+			latest = s
+		}
+	}
+
+	n := float64(len(samples))
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, time.Time{}, fmt.Errorf("usage samples must span more than one point in time")
+	}
+	slope := (n*sumXY - sumX*sumY) / denom
+	intercept := (sumY - slope*sumX) / n
+
+	if slope <= 0 {
+		return slope, time.Time{}, nil
+	}
+	secondsToFull := (float64(latest.Capacity) - intercept) / slope
+	// a time.Duration only reaches about 292 years
+	if math.IsNaN(secondsToFull) || secondsToFull >= math.MaxInt64/float64(time.Second) {
+		return slope, time.Time{}, nil
+	}
+	return slope, origin.Add(time.Duration(secondsToFull * float64(time.Second))), nil
+}
+
+// DefaultSmoothingFactor weighs each new sample at 30%
+const DefaultSmoothingFactor = 0.3
+
+// DriveCapacityTracker keeps an exponential moving average of a drive's
+// free space, so placement does not swing with every short-lived volume
+type DriveCapacityTracker struct {
+	lock     sync.Mutex
+	alpha    float64
+	smoothed float64
+	seeded   bool
+}
+
+// NewDriveCapacityTracker returns a tracker weighing new samples by alpha,
+// which must be in (0, 1]. Higher values follow changes more closely.
+func NewDriveCapacityTracker(alpha float64) (*DriveCapacityTracker, error) {
+	if alpha <= 0 || alpha > 1 {
+		return nil, fmt.Errorf("smoothing factor must be in (0, 1], got %v", alpha)
+	}
+	return &DriveCapacityTracker{alpha: alpha}, nil
+}
+
+func (t *DriveCapacityTracker) Update(free uint64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.seeded {
+		t.smoothed = float64(free)
+		t.seeded = true
+		return
+	}
+	t.smoothed = t.alpha*float64(free) + (1-t.alpha)*t.smoothed
+}
+
+func (t *DriveCapacityTracker) Smoothed() uint64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return uint64(t.smoothed)
+}
@@ -0,0 +1,72 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGrowthRate(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	testCases := []struct {
+		name    string
+		samples []UsageSample
+		slope   float64
+		eta     time.Time
+	}{
+		{
+			name: "growing",
+			samples: []UsageSample{
+				{Time: start, Used: 100, Capacity: 1000},
+				{Time: start.Add(100 * time.Second), Used: 200, Capacity: 1000},
+			},
+			slope: 1,
+			eta:   start.Add(900 * time.Second),
+		},
+		{
+			name: "flat",
+			samples: []UsageSample{
+				{Time: start, Used: 100, Capacity: 1000},
+				{Time: start.Add(time.Hour), Used: 100, Capacity: 1000},
+			},
+		},
+		{
+			name: "full in more than 292 years",
+			samples: []UsageSample{
+				{Time: start, Used: 0, Capacity: 100 * TiB},
+				{Time: start.Add(24 * time.Hour), Used: 1, Capacity: 100 * TiB},
+			},
+			slope: 1.0 / 86400,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			slope, eta, err := GrowthRate(testCase.samples)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := slope - testCase.slope; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("expected slope %v, got %v", testCase.slope, slope)
+			}
+			if !eta.Equal(testCase.eta) {
+				t.Errorf("expected eta %v, got %v", testCase.eta, eta)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// PathCaps are the capabilities of the filesystem a path lives on
+type PathCaps struct {
+	FSType  FSType
+	Reflink bool
+	Trim    bool
+	Quota   bool
+}
+
+// NodeCaps is what the node's kernel, filesystems and tools support
+type NodeCaps struct {
+	// Paths has an entry for each path NodeCapabilities was given
+	Paths map[string]PathCaps
+	// Quota is set when the kernel was built with quota support
+	Quota bool
+	// CgroupIO is set when the cgroup v2 io controller is available,
+	// which SetIOLimit needs
+	CgroupIO bool
+	// ZonedDevices lists the host-managed and host-aware block devices
+	ZonedDevices []string
+	// MkfsTools lists the filesystems whose mkfs is installed
+	MkfsTools []FSType
+}
+
+func cgroupIOAvailable() bool {
+	root := filepath.Join(SysfsRoot, "fs", "cgroup")
+	if ok, err := isCgroup2(root); err != nil || !ok {
+		return false
+	}
+	b, err := ioutil.ReadFile(filepath.Join(root, "cgroup.controllers"))
+	if err != nil {
+		return false
+	}
+	for _, c := range strings.Fields(string(b)) {
+		if c == "io" {
+			return true
+		}
+	}
+	return false
+}
+
+func zonedDevices() ([]string, error) {
+	entries, err := ioutil.ReadDir(sysClassBlock())
+	if err != nil {
+		return nil, err
+	}
+	zoned := []string{}
+	for _, e := range entries {
+		b, err := ioutil.ReadFile(filepath.Join(sysClassBlock(), e.Name(), "queue", "zoned"))
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(b)) != ZoneModelNone {
+			zoned = append(zoned, e.Name())
+		}
+	}
+	return zoned, nil
+}
+
+func pathCaps(path string) (PathCaps, error) {
+	info, err := findMountOf(path)
+	if err != nil {
+		return PathCaps{}, err
+	}
+	caps := PathCaps{FSType: FSType(info.FsType)}
+	if caps.Reflink, err = SupportsReflink(path); err != nil {
+		return PathCaps{}, err
+	}
+	if caps.Trim, err = SupportsTrim(info.MountPoint); err != nil {
+		return PathCaps{}, err
+	}
+	caps.Quota = supportsQuota(caps.FSType)
+	return caps, nil
+}
+
+// NodeCapabilities reports what this node supports, with filesystem
+// capabilities worked out for each of paths, typically the base paths
+// volumes are provisioned under. Controllers can check requested
+// features against it before provisioning.
+func NodeCapabilities(paths ...string) (*NodeCaps, error) {
+	caps := &NodeCaps{
+		Paths:    map[string]PathCaps{},
+		CgroupIO: cgroupIOAvailable(),
+	}
+	if _, err := os.Stat(filepath.Join(ProcRoot, "sys", "fs", "quota")); err == nil {
+		caps.Quota = true
+	}
+
+	zoned, err := zonedDevices()
+	if err != nil {
+		return nil, err
+	}
+	caps.ZonedDevices = zoned
+
+	for _, fsType := range []FSType{FSTypeXFS, FSTypeEXT4, FSTypeBtrfs} {
+		if _, err := lookPath("mkfs." + string(fsType)); err == nil {
+			caps.MkfsTools = append(caps.MkfsTools, fsType)
+		}
+	}
+
+	for _, p := range paths {
+		pc, err := pathCaps(p)
+		if err != nil {
+			return nil, err
+		}
+		if !caps.Quota {
+			pc.Quota = false
+		}
+		caps.Paths[p] = pc
+		glog.V(5).Infof("capabilities of %s: %+v", p, pc)
+	}
+	return caps, nil
+}
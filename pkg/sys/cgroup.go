@@ -0,0 +1,71 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+const cgroup2SuperMagic = 0x63677270
+
+var ErrNoCgroup2 = errors.New("cgroup v2 is not mounted")
+
+func isCgroup2(path string) (bool, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return false, err
+	}
+	return st.Type == cgroup2SuperMagic, nil
+}
+
+// SetIOLimit throttles I/O to devName from the processes in the cgroup v2
+// directory cgroupPath by writing its io.max. A zero value leaves that
+// limit unset.
+func SetIOLimit(devName string, cgroupPath string, rbps, wbps, riops, wiops uint64) error {
+	ok, err := isCgroup2(cgroupPath)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoCgroup2, cgroupPath)
+	}
+
+	rdev, err := blockDeviceRdev(getBlockFile(devName))
+	if err != nil {
+		return err
+	}
+
+	limit := func(v uint64) string {
+		if v == 0 {
+			return "max"
+		}
+		return strconv.FormatUint(v, 10)
+	}
+	line := fmt.Sprintf("%d:%d rbps=%s wbps=%s riops=%s wiops=%s",
+		unix.Major(rdev), unix.Minor(rdev), limit(rbps), limit(wbps), limit(riops), limit(wiops))
+
+	if err := ioutil.WriteFile(filepath.Join(cgroupPath, "io.max"), []byte(line), 0644); err != nil {
+		return fmt.Errorf("could not set io limit on %s for %s: %v", cgroupPath, devName, err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DriveAttributes are the properties of a drive that class rules match on
+type DriveAttributes struct {
+	Transport  string
+	Rotational bool
+	Size       uint64
+	Model      string
+	// ByPath is the /dev/disk/by-path name of the drive, which stays the
+	// same for whatever drive sits in that port or slot
+	ByPath string
+}
+
+// ReadDriveAttributes collects the DriveAttributes of devName from sysfs
+func ReadDriveAttributes(devName string) (*DriveAttributes, error) {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := Transport(disk)
+	if err != nil {
+		return nil, err
+	}
+	rotational, err := IsRotational(disk)
+	if err != nil {
+		return nil, err
+	}
+	size, err := ReadDeviceSize(devName)
+	if err != nil {
+		return nil, err
+	}
+	// nvme namespaces keep the model on the controller, one level up
+	model := ""
+	for _, p := range []string{"device/model", "device/device/model"} {
+		if b, err := ioutil.ReadFile(filepath.Join(sysClassBlock(), disk, p)); err == nil {
+			model = strings.TrimSpace(string(b))
+			break
+		}
+	}
+	return &DriveAttributes{
+		Transport:  transport,
+		Rotational: rotational,
+		Size:       size,
+		Model:      model,
+		ByPath:     byPathName(devName),
+	}, nil
+}
+
+// byPathName returns the by-path link pointing at devName, if any
+func byPathName(devName string) string {
+	dir := filepath.Join(DevRoot, "disk", "by-path")
+	links, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	want := blockName(devName)
+	for _, link := range links {
+		target, err := os.Readlink(filepath.Join(dir, link.Name()))
+		if err == nil && filepath.Base(target) == want {
+			return link.Name()
+		}
+	}
+	return ""
+}
+
+// ClassRule assigns Class to drives matching all of its set conditions.
+// Rules are meant to be loaded from operator configuration, hence the
+// json tags.
+type ClassRule struct {
+	Class      string `json:"class"`
+	Transport  string `json:"transport,omitempty"`
+	Rotational *bool  `json:"rotational,omitempty"`
+	MinSize    uint64 `json:"minSize,omitempty"`
+	MaxSize    uint64 `json:"maxSize,omitempty"`
+	ModelRegex string `json:"modelRegex,omitempty"`
+}
+
+// Validate checks that the rule has a class and a usable model regex
+func (r ClassRule) Validate() error {
+	if r.Class == "" {
+		return fmt.Errorf("class rule has no class name")
+	}
+	if r.MaxSize != 0 && r.MaxSize < r.MinSize {
+		return fmt.Errorf("class rule %s: maxSize is below minSize", r.Class)
+	}
+	if r.ModelRegex != "" {
+		if _, err := regexp.Compile(r.ModelRegex); err != nil {
+			return fmt.Errorf("class rule %s: invalid modelRegex: %v", r.Class, err)
+		}
+	}
+	return nil
+}
+
+func (r ClassRule) matches(drive DriveAttributes) bool {
+	if r.Transport != "" && r.Transport != drive.Transport {
+		return false
+	}
+	if r.Rotational != nil && *r.Rotational != drive.Rotational {
+		return false
+	}
+	if drive.Size < r.MinSize {
+		return false
+	}
+	if r.MaxSize != 0 && drive.Size > r.MaxSize {
+		return false
+	}
+	if r.ModelRegex != "" {
+		matched, err := regexp.MatchString(r.ModelRegex, drive.Model)
+		if err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// DriveClass returns the class of the first rule in rules that drive
+// matches, or an empty string if none does
+func DriveClass(drive DriveAttributes, rules []ClassRule) string {
+	for _, rule := range rules {
+		if rule.matches(drive) {
+			return rule.Class
+		}
+	}
+	return ""
+}
+
+// DriveClassOf returns the class of the drive holding the filesystem
+// path lives on
+func DriveClassOf(path string, rules []ClassRule) (string, error) {
+	if len(rules) == 0 {
+		return "", nil
+	}
+	info, err := findMountOf(path)
+	if err != nil {
+		return "", err
+	}
+	drive, err := ReadDriveAttributes(info.Source)
+	if err != nil {
+		return "", fmt.Errorf("could not read attributes of %s: %v", info.Source, err)
+	}
+	return DriveClass(*drive, rules), nil
+}
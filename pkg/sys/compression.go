@@ -0,0 +1,128 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type CompressionInfo struct {
+	// Algorithm is empty when compression is off
+	Algorithm string
+	// Level is 0 for the algorithm's default
+	Level int
+	// Forced is set for btrfs compress-force, which skips the check for
+	// incompressible data
+	Forced bool
+}
+
+// parseCompression parses values like zstd:3 (btrfs) or zstd-3 (zfs)
+func parseCompression(value string, sep string) CompressionInfo {
+	parts := strings.SplitN(value, sep, 2)
+	info := CompressionInfo{Algorithm: parts[0]}
+	if len(parts) == 2 {
+		info.Level, _ = strconv.Atoi(parts[1])
+	}
+	switch info.Algorithm {
+	case "no", "none", "off":
+		return CompressionInfo{}
+	}
+	return info
+}
+
+func readBtrfsCompression(mountpoint string) (*CompressionInfo, error) {
+	info, err := findMount(mountpoint)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range info.SuperOptions {
+		switch {
+		case strings.HasPrefix(o, "compress-force="):
+			c := parseCompression(strings.TrimPrefix(o, "compress-force="), ":")
+			c.Forced = true
+			return &c, nil
+		case strings.HasPrefix(o, "compress="):
+			c := parseCompression(strings.TrimPrefix(o, "compress="), ":")
+			return &c, nil
+		}
+	}
+	return &CompressionInfo{}, nil
+}
+
+func readZFSCompression(dataset string) (*CompressionInfo, error) {
+	out, stderr, err := run(context.Background(), "zfs", "get", "-H", "-o", "value", "compression", dataset)
+	if err != nil {
+		return nil, fmt.Errorf("could not read compression of %s: %v: %s", dataset, err, stderr)
+	}
+	c := parseCompression(strings.TrimSpace(string(out)), "-")
+	return &c, nil
+}
+
+// ReadCompression reports the transparent compression settings of a
+// mounted btrfs filesystem or of a zfs dataset. ErrUnsupportedOperation is
+// returned for filesystems without transparent compression.
+func ReadCompression(mountOrDev string, fsType FSType) (*CompressionInfo, error) {
+	switch fsType {
+	case FSTypeBtrfs:
+		return readBtrfsCompression(mountOrDev)
+	case FSTypeZFS:
+		return readZFSCompression(mountOrDev)
+	}
+	return nil, unsupported(fsType, "compression")
+}
+
+// SetCompression sets the compression of a btrfs filesystem. On the mount
+// point of the whole filesystem it is remounted with compress=, which also
+// takes a level; on any other path, volume bind mounts included, the btrfs
+// compression property is set, which applies to files written below it
+// from then on and leaves other volumes on the drive alone.
+func SetCompression(path string, fsType FSType, c CompressionInfo) error {
+	if fsType != FSTypeBtrfs {
+		return unsupported(fsType, "compression")
+	}
+
+	if info, err := findMount(path); err == nil && !isBindMount(info) {
+		option := "compress="
+		if c.Forced {
+			option = "compress-force="
+		}
+		switch {
+		case c.Algorithm == "":
+			option += "no"
+		case c.Level != 0:
+			option += fmt.Sprintf("%s:%d", c.Algorithm, c.Level)
+		default:
+			option += c.Algorithm
+		}
+		return RemountWithOptions(path, []string{option})
+	}
+
+	if c.Level != 0 || c.Forced {
+		return fmt.Errorf("compression level and forcing can only be set on the mount point of a whole btrfs filesystem, not %s", path)
+	}
+	value := c.Algorithm
+	if value == "" {
+		value = "none"
+	}
+	if _, stderr, err := run(context.Background(), "btrfs", "property", "set", path, "compression", value); err != nil {
+		return fmt.Errorf("could not set compression of %s: %v: %s", path, err, stderr)
+	}
+	return nil
+}
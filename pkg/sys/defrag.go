@@ -0,0 +1,88 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/golang/glog"
+)
+
+var e4defragScore = regexp.MustCompile(`Fragmentation score\s+(\d+)`)
+
+type DefragReport struct {
+	// ScoreBefore and ScoreAfter are e4defrag's fragmentation scores,
+	// from 0 to 100, or -1 where the tool does not report one
+	ScoreBefore int
+	ScoreAfter  int
+}
+
+type DefragOptions struct {
+	// IOPriority defaults to BackgroundIOPriority
+	IOPriority IOPriority
+}
+
+func e4defragScoreOf(ctx context.Context, path string, prio IOPriority) (int, error) {
+	out, stderr, err := runWithIOPriority(ctx, prio, "e4defrag", "-c", path)
+	if err != nil {
+		return -1, fmt.Errorf("e4defrag -c %s failed: %v: %s", path, err, stderr)
+	}
+	m := e4defragScore.FindSubmatch(out)
+	if m == nil {
+		return -1, nil
+	}
+	return strconv.Atoi(string(m[1]))
+}
+
+// Defragment defragments the mounted filesystem at path online, with
+// e4defrag for ext4 and xfs_fsr for XFS. The tools are killed if ctx is
+// cancelled.
+func Defragment(ctx context.Context, path string, fsType FSType, opts DefragOptions) (*DefragReport, error) {
+	report := &DefragReport{ScoreBefore: -1, ScoreAfter: -1}
+
+	var cmd []string
+	switch fsType {
+	case FSTypeEXT4:
+		score, err := e4defragScoreOf(ctx, path, opts.IOPriority)
+		if err != nil {
+			return nil, err
+		}
+		report.ScoreBefore = score
+		cmd = []string{"e4defrag", path}
+	case FSTypeXFS:
+		cmd = []string{"xfs_fsr", path}
+	default:
+		return nil, unsupported(fsType, "defragmentation")
+	}
+
+	if _, stderr, err := runWithIOPriority(ctx, opts.IOPriority, cmd[0], cmd[1:]...); err != nil {
+		return nil, fmt.Errorf("defragmentation of %s failed: %v: %s", path, err, stderr)
+	}
+
+	if fsType == FSTypeEXT4 {
+		score, err := e4defragScoreOf(ctx, path, opts.IOPriority)
+		if err != nil {
+			return nil, err
+		}
+		report.ScoreAfter = score
+	}
+	glog.V(5).Infof("defragmented %s: score %d -> %d", path, report.ScoreBefore, report.ScoreAfter)
+	return report, nil
+}
@@ -0,0 +1,113 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/glog"
+)
+
+// DeletionPolicy is how the space of a deleted volume is given back
+type DeletionPolicy string
+
+const (
+	// DeletionTrim removes the volume and discards the freed blocks
+	DeletionTrim DeletionPolicy = "trim"
+	// DeletionWipe overwrites every file of the volume with zeros before
+	// removing it
+	DeletionWipe DeletionPolicy = "wipe"
+)
+
+// DeletionPolicies maps drive classes, as returned by DriveClass, to
+// their deletion policy. Classes not listed get DeletionTrim.
+var DeletionPolicies = map[string]DeletionPolicy{}
+
+// DeletionPolicyFor returns the deletion policy of a drive class
+func DeletionPolicyFor(class string) DeletionPolicy {
+	if p, ok := DeletionPolicies[class]; ok {
+		return p
+	}
+	return DeletionTrim
+}
+
+func zeroFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zeros := make([]byte, MiB)
+	for left := info.Size(); left > 0; {
+		n := int64(len(zeros))
+		if left < n {
+			n = left
+		}
+		if _, err := f.Write(zeros[:n]); err != nil {
+			return err
+		}
+		left -= n
+	}
+	return f.Sync()
+}
+
+// ReclaimVolume removes the volume directory at path, which lives on the
+// filesystem mounted at mountpoint, according to policy. Wiping happens
+// in place through the filesystem, so on copy-on-write filesystems and
+// on SSDs old copies of the data may survive in remapped blocks.
+func ReclaimVolume(path, mountpoint string, policy DeletionPolicy) error {
+	switch policy {
+	case DeletionWipe:
+		err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+			return zeroFile(p)
+		})
+		if err != nil {
+			return fmt.Errorf("could not wipe %s: %v", path, err)
+		}
+	case DeletionTrim:
+	default:
+		return fmt.Errorf("invalid deletion policy %q", policy)
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	if policy != DeletionTrim {
+		return nil
+	}
+
+	trimmed, err := TrimFilesystem(mountpoint)
+	if err != nil {
+		if errors.Is(err, ErrTrimUnsupported) {
+			return nil
+		}
+		return err
+	}
+	glog.V(5).Infof("trimmed %d bytes on %s after removing %s", trimmed, mountpoint, path)
+	return nil
+}
@@ -0,0 +1,375 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+type inodeKey struct {
+	dev uint64
+	ino uint64
+}
+
+type dirSizer struct {
+	ctx context.Context
+
+	lock sync.Mutex
+	// wake is signalled when directories are queued or the walk ends
+	wake *sync.Cond
+	// queue holds directories still to be read, pending those queued or
+	// being read; it is used as a stack, which keeps it short on deep trees
+	queue   []string
+	pending int
+	seen    map[inodeKey]struct{}
+	bytes   uint64
+	inodes  uint64
+	err     error
+}
+
+// account adds st to the totals unless its inode was already counted
+// through another hard link. The lock must be held.
+func (d *dirSizer) account(st *unix.Stat_t) {
+	if st.Nlink > 1 {
+		key := inodeKey{dev: uint64(st.Dev), ino: st.Ino}
+		if _, ok := d.seen[key]; ok {
+			return
+		}
+		d.seen[key] = struct{}{}
+	}
+	// st_blocks is always in 512 byte units
+	d.bytes += uint64(st.Blocks) * 512
+	d.inodes++
+}
+
+// next returns the next directory to read, or false once the walk is
+// complete or has failed
+func (d *dirSizer) next() (string, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for len(d.queue) == 0 && d.pending > 0 && d.err == nil {
+		d.wake.Wait()
+	}
+	if d.err != nil || d.pending == 0 {
+		return "", false
+	}
+	dir := d.queue[len(d.queue)-1]
+	d.queue = d.queue[:len(d.queue)-1]
+	return dir, true
+}
+
+// done accounts the entries read from a directory and queues its
+// subdirectories
+func (d *dirSizer) done(stats []*unix.Stat_t, subdirs []string, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	defer d.wake.Broadcast()
+	d.pending--
+	if err != nil {
+		if d.err == nil {
+			d.err = err
+		}
+		return
+	}
+	for _, st := range stats {
+		d.account(st)
+	}
+	d.queue = append(d.queue, subdirs...)
+	d.pending += len(subdirs)
+}
+
+func (d *dirSizer) read(dir string) ([]*unix.Stat_t, []string, error) {
+	if err := d.ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats := make([]*unix.Stat_t, 0, len(entries))
+	subdirs := []string{}
+	for _, entry := range entries {
+		p := filepath.Join(dir, entry.Name())
+		st, ok := entry.Sys().(*unix.Stat_t)
+		if !ok {
+			var s unix.Stat_t
+			if err := unix.Lstat(p, &s); err != nil {
+				return nil, nil, err
+			}
+			st = &s
+		}
+		stats = append(stats, st)
+		if entry.IsDir() {
+			subdirs = append(subdirs, p)
+		}
+	}
+	return stats, subdirs, nil
+}
+
+func (d *dirSizer) worker(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		dir, ok := d.next()
+		if !ok {
+			return
+		}
+		stats, subdirs, err := d.read(dir)
+		d.done(stats, subdirs, err)
+	}
+}
+
+// DirectorySize returns the disk usage and the number of inodes of the
+// tree at path, as du would report them. Hard linked files are counted
+// once. Directories are read by a pool of one worker per CPU, and the
+// walk stops early once ctx is done.
+func DirectorySize(ctx context.Context, path string) (bytes, inodes uint64, err error) {
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		return 0, 0, err
+	}
+
+	d := &dirSizer{
+		ctx:  ctx,
+		seen: map[inodeKey]struct{}{},
+	}
+	d.wake = sync.NewCond(&d.lock)
+	d.account(&st)
+	if st.Mode&unix.S_IFMT == unix.S_IFDIR {
+		d.queue = []string{path}
+		d.pending = 1
+
+		var wg sync.WaitGroup
+		for i := 0; i < runtime.NumCPU(); i++ {
+			wg.Add(1)
+			go d.worker(&wg)
+		}
+		wg.Wait()
+	}
+
+	if d.err != nil {
+		return 0, 0, d.err
+	}
+	return d.bytes, d.inodes, nil
+}
+
+type dirSizeEntry struct {
+	// done is closed once the walk has finished
+	done   chan struct{}
+	at     time.Time
+	bytes  uint64
+	inodes uint64
+	err    error
+}
+
+// DirectorySizeCache keeps DirectorySize results for TTL, so that callers
+// polling often, like kubelet asking for volume stats, do not walk the
+// same tree over and over. Concurrent calls for a path share one walk.
+type DirectorySizeCache struct {
+	TTL time.Duration
+	// Timeout bounds each walk. Walks are shared, so they do not stop
+	// when the caller that started them gives up. Zero means no limit.
+	Timeout time.Duration
+
+	lock    sync.Mutex
+	entries map[string]*dirSizeEntry
+}
+
+// DefaultDirectorySizeTimeout is the walk timeout NewDirectorySizeCache
+// sets.
+const DefaultDirectorySizeTimeout = 10 * time.Minute
+
+func NewDirectorySizeCache(ttl time.Duration) *DirectorySizeCache {
+	return &DirectorySizeCache{
+		TTL:     ttl,
+		Timeout: DefaultDirectorySizeTimeout,
+		entries: map[string]*dirSizeEntry{},
+	}
+}
+
+// DirectorySize returns the cached DirectorySize of path, walking it if
+// the result is older than TTL. Errors are not cached. ctx only bounds
+// the wait for the result, not the walk itself.
+func (c *DirectorySizeCache) DirectorySize(ctx context.Context, path string) (bytes, inodes uint64, err error) {
+	c.lock.Lock()
+	e, ok := c.entries[path]
+	if ok {
+		select {
+		case <-e.done:
+			if e.err != nil || time.Since(e.at) >= c.TTL {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		// drop results for volumes that are no longer asked about
+		for p, old := range c.entries {
+			select {
+			case <-old.done:
+				if time.Since(old.at) >= c.TTL {
+					delete(c.entries, p)
+				}
+			default:
+			}
+		}
+		e = &dirSizeEntry{done: make(chan struct{})}
+		c.entries[path] = e
+		walkCtx, cancel := context.Background(), context.CancelFunc(func() {})
+		if c.Timeout > 0 {
+			walkCtx, cancel = context.WithTimeout(walkCtx, c.Timeout)
+		}
+		go func() {
+			defer cancel()
+			e.bytes, e.inodes, e.err = DirectorySize(walkCtx, path)
+			e.at = time.Now()
+			close(e.done)
+		}()
+	}
+	c.lock.Unlock()
+
+	select {
+	case <-e.done:
+		return e.bytes, e.inodes, e.err
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	}
+}
+
+type PathSize struct {
+	Path string
+	// Size is the allocated size, which for directories includes
+	// everything below them
+	Size uint64
+}
+
+// pathSizeHeap is a min-heap, so the smallest of the current top entries
+// is the one to evict
+type pathSizeHeap []PathSize
+
+func (h pathSizeHeap) Len() int            { return len(h) }
+func (h pathSizeHeap) Less(i, j int) bool  { return h[i].Size < h[j].Size }
+func (h pathSizeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pathSizeHeap) Push(x interface{}) { *h = append(*h, x.(PathSize)) }
+func (h *pathSizeHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type topWalker struct {
+	ctx  context.Context
+	root string
+	n    int
+	top  pathSizeHeap
+	seen map[inodeKey]struct{}
+}
+
+func (w *topWalker) offer(p PathSize) {
+	if w.top.Len() < w.n {
+		heap.Push(&w.top, p)
+		return
+	}
+	if p.Size > w.top[0].Size {
+		w.top[0] = p
+		heap.Fix(&w.top, 0)
+	}
+}
+
+// size returns the allocated size of the tree at path, offering every
+// file and directory in it on the way
+func (w *topWalker) size(path string, st *unix.Stat_t) (uint64, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	var size uint64
+	if st.Nlink <= 1 || st.Mode&unix.S_IFMT == unix.S_IFDIR {
+		size = uint64(st.Blocks) * 512
+	} else {
+		key := inodeKey{dev: uint64(st.Dev), ino: st.Ino}
+		if _, ok := w.seen[key]; !ok {
+			w.seen[key] = struct{}{}
+			size = uint64(st.Blocks) * 512
+		}
+	}
+
+	if st.Mode&unix.S_IFMT == unix.S_IFDIR {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			p := filepath.Join(path, entry.Name())
+			est, ok := entry.Sys().(*unix.Stat_t)
+			if !ok {
+				est = &unix.Stat_t{}
+				if err := unix.Lstat(p, est); err != nil {
+					return 0, err
+				}
+			}
+			s, err := w.size(p, est)
+			if err != nil {
+				return 0, err
+			}
+			size += s
+		}
+	}
+
+	if path != w.root {
+		w.offer(PathSize{Path: path, Size: size})
+	}
+	return size, nil
+}
+
+// TopConsumers returns the n largest files and directories below path by
+// allocated size, largest first, counting hard linked files once. Only
+// the current top n are kept while walking, so memory does not grow with
+// the size of the tree.
+func TopConsumers(ctx context.Context, path string, n int) ([]PathSize, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid number of top consumers %d", n)
+	}
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		return nil, err
+	}
+
+	w := &topWalker{
+		ctx:  ctx,
+		root: path,
+		n:    n,
+		seen: map[inodeKey]struct{}{},
+	}
+	if _, err := w.size(path, &st); err != nil {
+		return nil, err
+	}
+
+	result := make([]PathSize, w.top.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(&w.top).(PathSize)
+	}
+	return result, nil
+}
@@ -0,0 +1,53 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirectorySizeCacheCallerCancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dirsize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := ioutil.WriteFile(filepath.Join(dir, "data"), make([]byte, 64*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewDirectorySizeCache(time.Minute)
+
+	// a caller giving up must not cancel the shared walk or leave a
+	// cancellation error behind for the next caller
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cache.DirectorySize(ctx, dir)
+
+	bytes, inodes, err := cache.DirectorySize(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bytes == 0 || inodes != 2 {
+		t.Errorf("expected a non-zero size and 2 inodes, got %v bytes and %v inodes", bytes, inodes)
+	}
+}
@@ -0,0 +1,98 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// ErrNoEnclosure matches the error returned for drives not behind an SES
+// enclosure, which have no slot or locate LED
+var ErrNoEnclosure = &ErrUnsupportedOperation{Operation: "enclosure"}
+
+var trailingNumber = regexp.MustCompile(`(\d+)\s*$`)
+
+// enclosureSlotDir returns the /sys/class/enclosure/<enclosure>/<slot>
+// directory of the disk holding devName. The SES driver links it from
+// the disk's scsi device as enclosure_device:<slot>.
+func enclosureSlotDir(devName string) (string, error) {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return "", err
+	}
+	links, err := filepath.Glob(filepath.Join(sysClassBlock(), disk, "device", "enclosure_device:*"))
+	if err != nil {
+		return "", err
+	}
+	if len(links) == 0 {
+		return "", fmt.Errorf("%s is not in an enclosure: %w", disk, unsupported("", "enclosure"))
+	}
+	return filepath.EvalSymlinks(links[0])
+}
+
+// ReadEnclosureSlot returns the SES enclosure and the slot the disk
+// holding devName sits in. ErrNoEnclosure is returned for drives not
+// behind an enclosure.
+func ReadEnclosureSlot(devName string) (string, int, error) {
+	dir, err := enclosureSlotDir(devName)
+	if err != nil {
+		return "", 0, err
+	}
+	enclosure := filepath.Base(filepath.Dir(dir))
+
+	// kernels since 4.19 expose the slot number, older ones only have it
+	// in the slot's name, e.g. "Slot 14" or "DISK014"
+	if slot, err := readSysfsUint(filepath.Join(dir, "slot")); err == nil {
+		return enclosure, int(slot), nil
+	}
+	m := trailingNumber.FindStringSubmatch(filepath.Base(dir))
+	if m == nil {
+		return "", 0, fmt.Errorf("could not find slot number of %s in %s", devName, dir)
+	}
+	slot, err := strconv.Atoi(m[1])
+	if err != nil {
+		return "", 0, err
+	}
+	return enclosure, slot, nil
+}
+
+// SetLocateLED turns the locate LED of the bay holding devName on or
+// off, so the drive can be found before it is pulled. ErrNoEnclosure is
+// returned for drives not behind an enclosure.
+func SetLocateLED(devName string, on bool) error {
+	dir, err := enclosureSlotDir(devName)
+	if err != nil {
+		return err
+	}
+	value := "0"
+	if on {
+		value = "1"
+	}
+	p := filepath.Join(dir, "locate")
+	if err := ioutil.WriteFile(p, []byte(value), 0644); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("enclosure of %s has no locate LED: %w", devName, unsupported("", "locate LED"))
+		}
+		return fmt.Errorf("could not set locate LED of %s: %v", devName, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	ext4SuperblockOffset = 1024
+	ext4Magic            = 0xef53
+
+	ext4FeatureROCompatBigalloc = 0x200
+)
+
+// leading part of the on-disk struct ext4_super_block, little endian
+type ext4Superblock struct {
+	InodesCount       uint32
+	BlocksCountLo     uint32
+	RBlocksCountLo    uint32
+	FreeBlocksCountLo uint32
+	FreeInodesCount   uint32
+	FirstDataBlock    uint32
+	LogBlockSize      uint32
+	LogClusterSize    uint32
+	BlocksPerGroup    uint32
+	ClustersPerGroup  uint32
+	InodesPerGroup    uint32
+	MTime             uint32
+	WTime             uint32
+	MntCount          uint16
+	MaxMntCount       uint16
+	Magic             uint16
+	State             uint16
+	Errors            uint16
+	MinorRevLevel     uint16
+	LastCheck         uint32
+	CheckInterval     uint32
+	CreatorOS         uint32
+	RevLevel          uint32
+	DefResUID         uint16
+	DefResGID         uint16
+	FirstIno          uint32
+	InodeSize         uint16
+	BlockGroupNr      uint16
+	FeatureCompat     uint32
+	FeatureIncompat   uint32
+	FeatureROCompat   uint32
+	UUID              [16]byte
+	VolumeName        [16]byte
+}
+
+func readEXT4Superblock(r io.ReaderAt) (*ext4Superblock, error) {
+	sb := &ext4Superblock{}
+	if err := binary.Read(io.NewSectionReader(r, ext4SuperblockOffset, int64(binary.Size(sb))), binary.LittleEndian, sb); err != nil {
+		return nil, err
+	}
+	if sb.Magic != ext4Magic {
+		return nil, fmt.Errorf("no ext4 superblock found")
+	}
+	return sb, nil
+}
+
+// EXT4AllocationUnit returns the block size of the ext4 filesystem on
+// devName and the cluster size it allocates in. The two only differ with
+// the bigalloc feature, in which case capacity and quota math has to be
+// done in clusters.
+func EXT4AllocationUnit(devName string) (blockSize, clusterSize uint64, err error) {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	sb, err := readEXT4Superblock(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %v", devName, err)
+	}
+	blockSize = 1024 << sb.LogBlockSize
+	if sb.FeatureROCompat&ext4FeatureROCompatBigalloc == 0 {
+		return blockSize, blockSize, nil
+	}
+	return blockSize, 1024 << sb.LogClusterSize, nil
+}
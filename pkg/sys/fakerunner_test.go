@@ -0,0 +1,114 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// FakeExitError is the error of a faked command that exited with Code
+type FakeExitError struct {
+	Code int
+}
+
+func (e *FakeExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+func (e *FakeExitError) ExitCode() int {
+	return e.Code
+}
+
+// FakeCommand is a command run by a FakeCommandRunner
+type FakeCommand struct {
+	Name  string
+	Args  []string
+	Stdin []byte
+}
+
+// FakeOutput is what a faked command returns
+type FakeOutput struct {
+	Stdout []byte
+	Stderr []byte
+	Err    error
+}
+
+// FakeCommandRunner is a CommandRunner for tests. It records every
+// command and answers with the output set for its whole command line,
+// e.g. "dmsetup status pool", or else for its name; commands without
+// either fail as if they were not installed.
+type FakeCommandRunner struct {
+	Outputs map[string]FakeOutput
+
+	lock     sync.Mutex
+	commands []FakeCommand
+}
+
+func NewFakeCommandRunner() *FakeCommandRunner {
+	return &FakeCommandRunner{Outputs: map[string]FakeOutput{}}
+}
+
+func (f *FakeCommandRunner) Run(ctx context.Context, stdin io.Reader, name string, args ...string) ([]byte, []byte, error) {
+	cmd := FakeCommand{Name: name, Args: args}
+	if stdin != nil {
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return nil, nil, err
+		}
+		cmd.Stdin = b
+	}
+	f.lock.Lock()
+	f.commands = append(f.commands, cmd)
+	out, ok := f.Outputs[strings.Join(append([]string{name}, args...), " ")]
+	if !ok {
+		out, ok = f.Outputs[name]
+	}
+	f.lock.Unlock()
+
+	if !ok {
+		return nil, nil, fmt.Errorf("exec: %q: executable file not found in $PATH", name)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return out.Stdout, out.Stderr, out.Err
+}
+
+// LookPath finds commands that have an output set, for their name alone
+// or for any of their command lines
+func (f *FakeCommandRunner) LookPath(name string) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for cmdline := range f.Outputs {
+		if cmdline == name || strings.HasPrefix(cmdline, name+" ") {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("exec: %q: executable file not found in $PATH", name)
+}
+
+// Commands returns the commands run so far
+func (f *FakeCommandRunner) Commands() []FakeCommand {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]FakeCommand{}, f.commands...)
+}
@@ -0,0 +1,138 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"regexp"
+)
+
+const (
+	ClaimCeph = "ceph"
+	ClaimLVM  = "lvm"
+	ClaimZFS  = "zfs"
+
+	// ZFS keeps its uberblock ring in the second half of the first
+	// 256KiB vdev label
+	zfsUberblockOffset = 128 * KiB
+	zfsUberblockMagic  = 0x00bab10c
+
+	lvmLabelSectors = 4
+	lvmMetadataScan = MiB
+)
+
+var (
+	cephBluestoreMagic = []byte("bluestore block device\n")
+	lvmLabel           = []byte("LABELONE")
+	lvmType            = []byte("LVM2 001")
+
+	// LVM text metadata starts with "<vg name> {" followed by the VG id
+	lvmVGName = regexp.MustCompile(`(?m)^([A-Za-z0-9+_.-]+) \{\nid = "`)
+)
+
+func readAtFull(r io.ReaderAt, size int, offset int64) ([]byte, error) {
+	buf := make([]byte, size)
+	n, err := r.ReadAt(buf, offset)
+	if err == io.EOF {
+		err = nil
+	}
+	return buf[:n], err
+}
+
+// lvmVolumeGroup returns the volume group of an LVM physical volume, ok
+// being false when r is not a physical volume
+func lvmVolumeGroup(r io.ReaderAt) (string, bool, error) {
+	isPV := false
+	for sector := int64(0); sector < lvmLabelSectors; sector++ {
+		label, err := readAtFull(r, 32, sector*512)
+		if err != nil {
+			return "", false, err
+		}
+		if len(label) == 32 && bytes.Equal(label[:8], lvmLabel) && bytes.Equal(label[24:32], lvmType) {
+			isPV = true
+			break
+		}
+	}
+	if !isPV {
+		return "", false, nil
+	}
+
+	// a PV not in any VG has no metadata, which leaves the name empty
+	metadata, err := readAtFull(r, int(lvmMetadataScan), 0)
+	if err != nil {
+		return "", true, err
+	}
+	if m := lvmVGName.FindSubmatch(metadata); m != nil {
+		return string(m[1]), true, nil
+	}
+	return "", true, nil
+}
+
+func hasZFSLabel(r io.ReaderAt) (bool, error) {
+	b, err := readAtFull(r, 8, int64(zfsUberblockOffset))
+	if err != nil || len(b) < 8 {
+		return false, err
+	}
+	// uberblocks are written in the byte order of the host that wrote them
+	return binary.LittleEndian.Uint64(b) == zfsUberblockMagic ||
+		binary.BigEndian.Uint64(b) == zfsUberblockMagic, nil
+}
+
+// DetectForeignCSIClaim reports whether devName carries the on-disk
+// signature of another storage provisioner and which one: ClaimCeph for
+// Rook/Ceph OSDs, ClaimLVM, suffixed with ":<volume group>" when it has
+// one, for TopoLVM and other LVM based provisioners, and ClaimZFS for
+// OpenEBS ZFS and cStor pools. Drives with any of these must not be
+// claimed, since the other provisioner may still be using them.
+func DetectForeignCSIClaim(devName string) (bool, string, error) {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return false, "", err
+	}
+	defer f.Close()
+
+	head, err := readAtFull(f, len(cephBluestoreMagic), 0)
+	if err != nil {
+		return false, "", err
+	}
+	if bytes.Equal(head, cephBluestoreMagic) {
+		return true, ClaimCeph, nil
+	}
+
+	vg, isPV, err := lvmVolumeGroup(f)
+	if err != nil {
+		return false, "", err
+	}
+	if isPV {
+		if vg == "" {
+			return true, ClaimLVM, nil
+		}
+		return true, ClaimLVM + ":" + vg, nil
+	}
+
+	zfs, err := hasZFSLabel(f)
+	if err != nil {
+		return false, "", err
+	}
+	if zfs {
+		return true, ClaimZFS, nil
+	}
+	return false, "", nil
+}
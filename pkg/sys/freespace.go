@@ -0,0 +1,131 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const btrfsBlockGroupData = 1 << 0
+
+type btrfsSpaceArgs struct {
+	spaceSlots  uint64
+	totalSpaces uint64
+}
+
+type btrfsSpaceInfo struct {
+	flags      uint64
+	totalBytes uint64
+	usedBytes  uint64
+}
+
+var btrfsIocSpaceInfo = iocIOWR(btrfsIoctlMagic, 20, unsafe.Sizeof(btrfsSpaceArgs{}))
+
+// btrfsSpaceInfos returns the per profile space usage of the mounted
+// btrfs filesystem in d
+func btrfsSpaceInfos(d *os.File) ([]btrfsSpaceInfo, error) {
+	// the first call with no slots only returns how many there are
+	args := btrfsSpaceArgs{}
+	if err := ioctl(d.Fd(), btrfsIocSpaceInfo, unsafe.Pointer(&args)); err != nil {
+		return nil, err
+	}
+	n := args.totalSpaces
+	if n == 0 {
+		return nil, nil
+	}
+
+	headerSize := unsafe.Sizeof(btrfsSpaceArgs{})
+	infoSize := unsafe.Sizeof(btrfsSpaceInfo{})
+	// uint64 backing keeps the buffer aligned for both structs
+	buf := make([]uint64, (headerSize+uintptr(n)*infoSize)/8)
+	header := (*btrfsSpaceArgs)(unsafe.Pointer(&buf[0]))
+	header.spaceSlots = n
+	if err := ioctl(d.Fd(), btrfsIocSpaceInfo, unsafe.Pointer(&buf[0])); err != nil {
+		return nil, err
+	}
+
+	infos := make([]btrfsSpaceInfo, header.totalSpaces)
+	for i := range infos {
+		infos[i] = *(*btrfsSpaceInfo)(unsafe.Pointer(uintptr(unsafe.Pointer(&buf[0])) + headerSize + uintptr(i)*infoSize))
+	}
+	return infos, nil
+}
+
+// MountedFreeSpace returns the free and total bytes of the filesystem
+// mounted at mountpoint as the running kernel accounts them, which for
+// btrfs can be far off from what its superblock claims. For btrfs, total
+// is what data can use: the data already stored plus the free space.
+func MountedFreeSpace(mountpoint string, fsType FSType) (free, total uint64, err error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(mountpoint, &st); err != nil {
+		return 0, 0, err
+	}
+	// f_bavail leaves out blocks reserved for root, which volumes cannot use
+	free = st.Bavail * uint64(st.Bsize)
+	total = st.Blocks * uint64(st.Bsize)
+	if fsType != FSTypeBtrfs {
+		return free, total, nil
+	}
+
+	d, err := os.Open(mountpoint)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer d.Close()
+	infos, err := btrfsSpaceInfos(d)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not read space info of %s: %v", mountpoint, err)
+	}
+
+	// btrfs statfs blocks count raw device space, counting every mirror
+	// of metadata and data alike, so total is rebuilt from the data
+	// actually stored instead
+	var dataUsed uint64
+	for _, info := range infos {
+		if info.flags&btrfsBlockGroupData != 0 {
+			dataUsed += info.usedBytes
+		}
+	}
+	return free, dataUsed + free, nil
+}
+
+// DefaultFreeReserve is the percentage of each filesystem held back from
+// allocation, past which it is known to slow down
+var DefaultFreeReserve = map[FSType]float64{
+	FSTypeXFS:   10,
+	FSTypeEXT4:  5,
+	FSTypeBtrfs: 10,
+	FSTypeZFS:   20,
+}
+
+// EffectiveFree returns how much of free may be allocated once
+// reservePercent of total is held back. A negative reservePercent picks
+// DefaultFreeReserve for fsType.
+func EffectiveFree(free, total uint64, fsType FSType, reservePercent float64) uint64 {
+	if reservePercent < 0 {
+		reservePercent = DefaultFreeReserve[fsType]
+	}
+	reserve := uint64(float64(total) * reservePercent / 100)
+	if free <= reserve {
+		return 0
+	}
+	return free - reserve
+}
@@ -0,0 +1,195 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+type FSType string
+
+const (
+	FSTypeXFS   FSType = "xfs"
+	FSTypeEXT4  FSType = "ext4"
+	FSTypeBtrfs FSType = "btrfs"
+	FSTypeZFS   FSType = "zfs"
+
+	FSTypeTmpfs   FSType = "tmpfs"
+	FSTypeOverlay FSType = "overlay"
+	FSTypeProc    FSType = "proc"
+	FSTypeSysfs   FSType = "sysfs"
+	FSTypeCgroup2 FSType = "cgroup2"
+)
+
+// fsMagics maps statfs f_type magic numbers to filesystem types. ext2
+// and ext3 share the ext4 magic.
+var fsMagics = map[int64]FSType{
+	unix.XFS_SUPER_MAGIC:       FSTypeXFS,
+	unix.EXT4_SUPER_MAGIC:      FSTypeEXT4,
+	unix.BTRFS_SUPER_MAGIC:     FSTypeBtrfs,
+	unix.TMPFS_MAGIC:           FSTypeTmpfs,
+	unix.OVERLAYFS_SUPER_MAGIC: FSTypeOverlay,
+	unix.PROC_SUPER_MAGIC:      FSTypeProc,
+	unix.SYSFS_MAGIC:           FSTypeSysfs,
+	cgroup2SuperMagic:          FSTypeCgroup2,
+}
+
+const (
+	KiB uint64 = 1 << (10 * (iota + 1))
+	MiB
+	GiB
+	TiB
+)
+
+var (
+	ErrTooSmall    = errors.New("device too small for filesystem")
+	ErrUnsupported = errors.New("operation not supported by filesystem")
+	ErrZoned       = errors.New("device is host-managed zoned")
+)
+
+// ErrUnsupportedOperation is returned when an operation is not available
+// on a filesystem. It matches ErrUnsupported with errors.Is, as well as
+// any ErrUnsupportedOperation whose non-empty fields are equal, so callers
+// can check for a whole class, e.g. every unsupported "compression".
+type ErrUnsupportedOperation struct {
+	FSType    FSType
+	Operation string
+}
+
+func (e *ErrUnsupportedOperation) Error() string {
+	if e.FSType == "" {
+		return fmt.Sprintf("%s is not supported", e.Operation)
+	}
+	return fmt.Sprintf("%s is not supported on %s", e.Operation, e.FSType)
+}
+
+func (e *ErrUnsupportedOperation) Is(target error) bool {
+	if target == ErrUnsupported {
+		return true
+	}
+	t, ok := target.(*ErrUnsupportedOperation)
+	if !ok {
+		return false
+	}
+	return (t.FSType == "" || t.FSType == e.FSType) &&
+		(t.Operation == "" || t.Operation == e.Operation)
+}
+
+func unsupported(fsType FSType, operation string) error {
+	return &ErrUnsupportedOperation{FSType: fsType, Operation: operation}
+}
+
+// fsTypeOf returns the type of the filesystem f is on, or its statfs
+// magic in hex if it is not one we know
+func fsTypeOf(f *os.File) FSType {
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(int(f.Fd()), &st); err != nil {
+		return ""
+	}
+	if fsType, ok := fsMagics[int64(st.Type)]; ok {
+		return fsType
+	}
+	return FSType(fmt.Sprintf("%#x", st.Type))
+}
+
+// MinimumSize returns the smallest device size, in bytes, on which mkfs
+// will create the given filesystem. Zero is returned for unknown types.
+func MinimumSize(fsType FSType) uint64 {
+	switch fsType {
+	case FSTypeXFS:
+		// mkfs.xfs historically refused anything below 16MiB; xfsprogs 5.19
+		// raised the floor to 300MiB, so we go with the newer limit
+		return 300 * MiB
+	case FSTypeEXT4:
+		// mke2fs needs room for the default 1024 block journal along with
+		// the superblock, group descriptors and inode tables
+		return 2 * MiB
+	case FSTypeBtrfs:
+		// mkfs.btrfs rejects devices smaller than 114294784 bytes for the
+		// default single data / dup metadata profile
+		return 109 * MiB
+	}
+	return 0
+}
+
+// ValidateFormatSize checks size against MinimumSize(fsType) so that
+// callers can fail with ErrTooSmall before ever invoking mkfs
+func ValidateFormatSize(fsType FSType, size uint64) error {
+	if min := MinimumSize(fsType); size < min {
+		return fmt.Errorf("%w: %s needs at least %d bytes, got %d", ErrTooSmall, fsType, min, size)
+	}
+	return nil
+}
+
+// SupportsZoned reports whether fsType can be created directly on a
+// host-managed zoned device
+func SupportsZoned(fsType FSType) bool {
+	return fsType == FSTypeBtrfs
+}
+
+// ValidateFormatZoned fails with ErrZoned if devName is host-managed
+// zoned and fsType cannot handle sequential write zones. Host-aware
+// devices accept random writes and are allowed.
+func ValidateFormatZoned(devName string, fsType FSType) error {
+	_, model, err := IsZoned(devName)
+	if err != nil {
+		return err
+	}
+	if model == ZoneModelHostManaged && !SupportsZoned(fsType) {
+		return fmt.Errorf("%w: %s does not support zoned devices, refusing to format %s", ErrZoned, fsType, devName)
+	}
+	return nil
+}
+
+// FSTypePolicy picks a filesystem for drives formatted without an
+// explicit type
+type FSTypePolicy struct {
+	// Drives larger than LargeDriveSize, or not rotational, get LargeFS
+	LargeDriveSize uint64
+	LargeFS        FSType
+	SmallFS        FSType
+}
+
+var DefaultFSTypePolicy = FSTypePolicy{
+	LargeDriveSize: 2 * TiB,
+	LargeFS:        FSTypeXFS,
+	SmallFS:        FSTypeEXT4,
+}
+
+func (p FSTypePolicy) Recommend(devName string) (FSType, error) {
+	size, err := DeviceSize(devName)
+	if err != nil {
+		return "", err
+	}
+	rotational, err := IsRotational(devName)
+	if err != nil {
+		return "", err
+	}
+	if size > p.LargeDriveSize || !rotational {
+		return p.LargeFS, nil
+	}
+	return p.SmallFS, nil
+}
+
+// RecommendFSType picks a filesystem for devName using DefaultFSTypePolicy
+func RecommendFSType(devName string) (FSType, error) {
+	return DefaultFSTypePolicy.Recommend(devName)
+}
@@ -0,0 +1,93 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+var ErrFsckTimeout = errors.New("filesystem check timed out")
+
+// FsckTimeouts bounds how long CheckFilesystem may run per filesystem;
+// repairing a large damaged XFS can take far longer than an ext4 check
+var FsckTimeouts = map[FSType]time.Duration{
+	FSTypeEXT4:  30 * time.Minute,
+	FSTypeXFS:   time.Hour,
+	FSTypeBtrfs: time.Hour,
+}
+
+func fsckCommand(device string, fsType FSType, repair bool) ([]string, error) {
+	switch fsType {
+	case FSTypeEXT4:
+		if repair {
+			return []string{"e2fsck", "-p", device}, nil
+		}
+		return []string{"e2fsck", "-n", device}, nil
+	case FSTypeXFS:
+		if repair {
+			return []string{"xfs_repair", device}, nil
+		}
+		return []string{"xfs_repair", "-n", device}, nil
+	case FSTypeBtrfs:
+		if repair {
+			return []string{"btrfs", "check", "--repair", device}, nil
+		}
+		return []string{"btrfs", "check", "--readonly", device}, nil
+	}
+	return nil, unsupported(fsType, "fsck")
+}
+
+// CheckFilesystem checks, and if repair is set repairs, the unmounted
+// filesystem on devName. If it runs past timeout, or FsckTimeouts[fsType]
+// when timeout is zero, the whole checker process group is killed and
+// ErrFsckTimeout returned; the filesystem must then be handled manually.
+func CheckFilesystem(devName string, fsType FSType, repair bool, timeout time.Duration) error {
+	cmd, err := fsckCommand(getBlockFile(devName), fsType, repair)
+	if err != nil {
+		return err
+	}
+	if timeout == 0 {
+		timeout = FsckTimeouts[fsType]
+	}
+
+	// the runner kills the checker's whole process group once ctx is done
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	stdout, stderr, err := run(ctx, cmd[0], cmd[1:]...)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w: %s after %v", ErrFsckTimeout, devName, timeout)
+	}
+	if err != nil {
+		// e2fsck exits with 1 when it corrected errors
+		if code, ok := exitCode(err); ok && fsType == FSTypeEXT4 && code == 1 {
+			glog.Infof("filesystem errors on %s were corrected: %s", devName, stdout)
+			return nil
+		}
+		return fmt.Errorf("filesystem check of %s failed: %v: %s%s", devName, err, stdout, stderr)
+	}
+	return nil
+}
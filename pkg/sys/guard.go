@@ -0,0 +1,185 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/utils/mount"
+)
+
+var (
+	ErrRootDisk     = errors.New("device holds the root filesystem")
+	ErrHasData      = errors.New("device holds existing data")
+	ErrMemberDevice = errors.New("device is a member of another block device")
+)
+
+type GuardOptions struct {
+	// Force allows destroying an existing filesystem, LUKS header or
+	// hibernation image. It never overrides the checks for devices in use.
+	Force bool
+}
+
+// holders lists the block devices, such as device mapper or md arrays,
+// built on top of devName
+func holders(devName string) ([]string, error) {
+	p, err := sysfsBlockPath(devName)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ioutil.ReadDir(filepath.Join(p, "holders"))
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
+var errHostRootUnknown = errors.New("could not find the block device of the host root filesystem")
+
+// hostRootDevice returns the kernel name of the device holding the root
+// filesystem of the host. Our own root is the container's overlay, so the
+// mount table of PID 1 is read instead, which is the host's init when
+// the plugin shares the host PID namespace.
+func hostRootDevice() (string, error) {
+	infos, err := mount.ParseMountInfo(filepath.Join(ProcRoot, "1", "mountinfo"))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errHostRootUnknown, err)
+	}
+	var root *mount.MountInfo
+	for i := range infos {
+		if infos[i].MountPoint == "/" {
+			root = &infos[i]
+		}
+	}
+	if root == nil {
+		return "", fmt.Errorf("%w: no / in mountinfo of PID 1", errHostRootUnknown)
+	}
+	if root.Major != 0 {
+		return deviceNameByNumber(fmt.Sprintf("%d:%d", root.Major, root.Minor))
+	}
+	// btrfs mounts carry an anonymous device number, but name their device
+	if strings.HasPrefix(root.Source, "/dev/") {
+		return blockName(root.Source), nil
+	}
+	return "", fmt.Errorf("%w: / is %s from %s, is the host PID namespace shared?", errHostRootUnknown, root.FsType, root.Source)
+}
+
+func isRootDisk(devName string) (bool, error) {
+	rootDev, err := hostRootDevice()
+	if err != nil {
+		return false, err
+	}
+	rootDisk, err := ParentDisk(rootDev)
+	if err != nil {
+		return false, err
+	}
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return false, err
+	}
+	return disk == rootDisk, nil
+}
+
+// GuardDestructive runs every check that must pass before data on
+// devName is destroyed. The device must not be mounted, hold the root
+// filesystem, be a member of an md, device mapper or bcache device, be
+// claimed by another provisioner, be open by any process or otherwise
+// held by the kernel. Unless opts.Force is set it must also hold no
+// filesystem, LUKS header or hibernation image; the last fails with
+// ErrHibernationImage rather than ErrHasData. It also fails when the
+// host root filesystem cannot be found, as the root disk could then not
+// be told apart.
+func GuardDestructive(devName string, opts GuardOptions) error {
+	if root, err := isRootDisk(devName); err != nil {
+		return err
+	} else if root {
+		return fmt.Errorf("%w: %s", ErrRootDisk, devName)
+	}
+
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return err
+	}
+	mounted, err := IsDeviceMounted(devName)
+	if err != nil {
+		return err
+	}
+	if !mounted && blockName(devName) == disk {
+		// wiping a whole disk destroys its partitions as well
+		if mounted, err = IsDiskMounted(devName); err != nil {
+			return err
+		}
+	}
+	if mounted {
+		return fmt.Errorf("%w: %s is mounted", ErrDeviceInUse, devName)
+	}
+
+	held, err := holders(devName)
+	if err != nil {
+		return err
+	}
+	if len(held) > 0 {
+		return fmt.Errorf("%w: %s is held by %v", ErrMemberDevice, devName, held)
+	}
+	if _, err := ProbeBcache(devName); err == nil {
+		return fmt.Errorf("%w: %s is a bcache device", ErrMemberDevice, devName)
+	} else if !errors.Is(err, ErrNotBcache) {
+		return err
+	}
+	claimed, by, err := DetectForeignCSIClaim(devName)
+	if err != nil {
+		return err
+	}
+	if claimed {
+		return fmt.Errorf("%w: %s is claimed by %s", ErrMemberDevice, devName, by)
+	}
+
+	busy, pids, err := IsDeviceBusy(devName)
+	if err != nil {
+		return err
+	}
+	if busy {
+		return fmt.Errorf("%w: %s is open by processes %v", ErrDeviceInUse, devName, pids)
+	}
+	if err := CheckNotInUse(devName); err != nil {
+		return err
+	}
+
+	if opts.Force {
+		return nil
+	}
+	if fsType, err := ProbeFSType(devName); err == nil {
+		return fmt.Errorf("%w: %s has a %s filesystem", ErrHasData, devName, fsType)
+	} else if !errors.Is(err, ErrUnknownFS) && !errors.Is(err, ErrEmptyDevice) {
+		// a device that cannot be read is not known to be blank
+		return fmt.Errorf("could not check %s for existing data: %w", devName, err)
+	}
+	if _, err := ReadLUKSHeader(devName); err == nil {
+		return fmt.Errorf("%w: %s is LUKS encrypted", ErrHasData, devName)
+	} else if !errors.Is(err, ErrNotLUKS) {
+		return err
+	}
+	return CheckHibernationImage(devName)
+}
@@ -0,0 +1,118 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeProcRoot points ProcRoot at a directory holding mountinfo for pid,
+// which may be self
+func fakeProcRoot(t *testing.T, pid string, mountinfo string) {
+	dir, err := ioutil.TempDir("", "proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldProcRoot := ProcRoot
+	ProcRoot = dir
+	t.Cleanup(func() {
+		ProcRoot = oldProcRoot
+		os.RemoveAll(dir)
+	})
+	if err := os.MkdirAll(filepath.Join(dir, pid), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, pid, "mountinfo"), []byte(mountinfo), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsRootDisk(t *testing.T) {
+	files := map[string]string{}
+	links := map[string]string{}
+	for _, dev := range []struct{ disk, part, number string }{
+		{"sda", "", "8:0"},
+		{"sda", "sda1", "8:1"},
+		{"sda", "sda2", "8:2"},
+		{"sdb", "", "8:16"},
+		{"sdb", "sdb2", "8:18"},
+	} {
+		p := filepath.Join("devices", "pci0000:00", "block", dev.disk)
+		name := dev.disk
+		if dev.part != "" {
+			p = filepath.Join(p, dev.part)
+			name = dev.part
+			files[filepath.Join(p, "partition")] = "1\n"
+		}
+		files[filepath.Join(p, "dev")] = dev.number + "\n"
+		links[filepath.Join("class", "block", name)] = filepath.Join("..", "..", p)
+		links[filepath.Join("dev", "block", dev.number)] = filepath.Join("..", "..", p)
+	}
+	fakeSysfs(t, files, links)
+
+	testCases := []struct {
+		name      string
+		mountinfo string
+		roots     []string
+		others    []string
+		err       error
+	}{
+		{
+			name: "ext4 root on a partition",
+			mountinfo: "22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw\n" +
+				"23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:5 - proc proc rw\n",
+			roots:  []string{"sda", "sda1", "sda2"},
+			others: []string{"sdb", "sdb2"},
+		},
+		{
+			name:      "btrfs root with an anonymous device number",
+			mountinfo: "30 1 0:27 /@ / rw,relatime shared:1 - btrfs /dev/sdb2 rw,space_cache\n",
+			roots:     []string{"sdb", "sdb2"},
+			others:    []string{"sda", "sda2"},
+		},
+		{
+			name:      "container root without the host PID namespace",
+			mountinfo: "600 500 0:50 / / rw,relatime - overlay overlay rw,lowerdir=/var/lib/a,upperdir=/var/lib/b\n",
+			others:    []string{"sda", "sdb"},
+			err:       errHostRootUnknown,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fakeProcRoot(t, "1", testCase.mountinfo)
+			for _, dev := range testCase.roots {
+				if root, err := isRootDisk(dev); err != nil || !root {
+					t.Errorf("%s: expected root disk, got %v, %v", dev, root, err)
+				}
+			}
+			for _, dev := range testCase.others {
+				root, err := isRootDisk(dev)
+				if !errors.Is(err, testCase.err) {
+					t.Errorf("%s: expected error %v, got %v", dev, testCase.err, err)
+				}
+				if root {
+					t.Errorf("%s: unexpected root disk", dev)
+				}
+			}
+		})
+	}
+}
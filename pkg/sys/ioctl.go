@@ -0,0 +1,73 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioctl request encoding, as done by the _IO* macros in <asm-generic/ioctl.h>
+const (
+	iocNone  = 0
+	iocWrite = 1
+	iocRead  = 2
+
+	iocNRShift   = 0
+	iocTypeShift = 8
+	iocSizeShift = 16
+	iocDirShift  = 30
+)
+
+func ioc(dir, typ, nr, size uintptr) uintptr {
+	return dir<<iocDirShift | typ<<iocTypeShift | nr<<iocNRShift | size<<iocSizeShift
+}
+
+func iocIO(typ, nr uintptr) uintptr {
+	return ioc(iocNone, typ, nr, 0)
+}
+
+func iocIOR(typ, nr, size uintptr) uintptr {
+	return ioc(iocRead, typ, nr, size)
+}
+
+func iocIOW(typ, nr, size uintptr) uintptr {
+	return ioc(iocWrite, typ, nr, size)
+}
+
+func iocIOWR(typ, nr, size uintptr) uintptr {
+	return ioc(iocRead|iocWrite, typ, nr, size)
+}
+
+// ioctl passes arg to the kernel as a pointer. It is only converted to a
+// uintptr within the Syscall expression, so the memory it points to stays
+// alive and in place until the call returns.
+func ioctl(fd, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ioctlValue is ioctl for requests taking an integer argument
+func ioctlValue(fd, req, arg uintptr) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+// IOClass is an I/O scheduling class, see ioprio_set(2)
+type IOClass int
+
+const (
+	// IOClassDefault leaves the choice to the operation, which for
+	// background work means IOClassIdle
+	IOClassDefault    IOClass = 0
+	IOClassRealtime   IOClass = 1
+	IOClassBestEffort IOClass = 2
+	IOClassIdle       IOClass = 3
+)
+
+// IOPriority is the I/O scheduling class and, for the realtime and
+// best-effort classes, the level from 0 (highest) to 7 (lowest)
+type IOPriority struct {
+	Class IOClass
+	Level int
+}
+
+// BackgroundIOPriority is what maintenance work runs at unless told
+// otherwise, so that it only gets disk time nobody else wants
+var BackgroundIOPriority = IOPriority{Class: IOClassIdle}
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+// orBackground resolves the default class to BackgroundIOPriority
+func (p IOPriority) orBackground() IOPriority {
+	if p.Class == IOClassDefault {
+		return BackgroundIOPriority
+	}
+	return p
+}
+
+func (p IOPriority) value() uintptr {
+	return uintptr(p.Class)<<ioprioClassShift | uintptr(p.Level)
+}
+
+// ioniceArgs prefixes a command line with ionice at priority p
+func (p IOPriority) ioniceArgs(name string, args ...string) []string {
+	ionice := []string{"-c", strconv.Itoa(int(p.Class))}
+	if p.Class == IOClassRealtime || p.Class == IOClassBestEffort {
+		ionice = append(ionice, "-n", strconv.Itoa(p.Level))
+	}
+	return append(append(ionice, name), args...)
+}
+
+// withIOPriority runs fn on an OS thread of its own at I/O priority p.
+// The I/O priority is per thread, so fn must do its I/O itself rather
+// than in goroutines it starts. The thread is discarded afterwards
+// instead of going back to the scheduler at the changed priority.
+func withIOPriority(p IOPriority, fn func() error) error {
+	p = p.orBackground()
+	done := make(chan error, 1)
+	go func() {
+		// a goroutine exiting while locked takes its thread with it
+		runtime.LockOSThread()
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, p.value()); errno != 0 {
+			glog.V(5).Infof("could not set I/O priority %+v: %v", p, errno)
+		}
+		done <- fn()
+	}()
+	return <-done
+}
+
+// runWithIOPriority runs an external tool at I/O priority p
+func runWithIOPriority(ctx context.Context, p IOPriority, name string, args ...string) ([]byte, []byte, error) {
+	return run(ctx, "ionice", p.orBackground().ioniceArgs(name, args...)...)
+}
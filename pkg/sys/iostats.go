@@ -0,0 +1,185 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// the block layer counts sectors in 512 byte units whatever the device's
+// logical sector size
+const statSectorSize = 512
+
+var ErrNoIOStats = errors.New("no io statistics for device in cgroup")
+
+// VolumeIO holds cumulative I/O counters sampled at Time
+type VolumeIO struct {
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadIOs    uint64
+	WriteIOs   uint64
+	Time       time.Time
+}
+
+// VolumeIOStats reads the I/O counters of devName from its sysfs stat
+// file. These count all I/O to the device, so when several volumes are
+// directories on the same drive the numbers are shared by all of them;
+// use CgroupIOStats to tell those volumes apart.
+func VolumeIOStats(devName string) (*VolumeIO, error) {
+	p, err := sysfsBlockPath(devName)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(p, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	// reads completed, reads merged, sectors read, time reading, writes
+	// completed, writes merged, sectors written, ...
+	fields := strings.Fields(string(b))
+	if len(fields) < 7 {
+		return nil, fmt.Errorf("could not parse io stats of %s: %q", devName, string(b))
+	}
+	values := make([]uint64, 7)
+	for i := range values {
+		if values[i], err = strconv.ParseUint(fields[i], 10, 64); err != nil {
+			return nil, fmt.Errorf("could not parse io stats of %s: %v", devName, err)
+		}
+	}
+	return &VolumeIO{
+		ReadIOs:    values[0],
+		ReadBytes:  values[2] * statSectorSize,
+		WriteIOs:   values[4],
+		WriteBytes: values[6] * statSectorSize,
+		Time:       now,
+	}, nil
+}
+
+// CgroupIOStats reads the I/O counters of the processes in the cgroup v2
+// directory cgroupPath against devName from its io.stat. This only tells
+// volumes on a shared drive apart if each is used from its own cgroup;
+// I/O from a pod writing to several volumes on one drive is counted
+// together, and writeback is charged to the cgroup that dirtied the page
+// cache, not necessarily the one owning the volume. ErrNoIOStats is
+// returned when the cgroup has not done any I/O to devName.
+func CgroupIOStats(cgroupPath string, devName string) (*VolumeIO, error) {
+	ok, err := isCgroup2(cgroupPath)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoCgroup2, cgroupPath)
+	}
+
+	// io.stat is keyed by the whole disk, partitions are not listed
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return nil, err
+	}
+	rdev, err := blockDeviceRdev(getBlockFile(disk))
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%d:%d", unix.Major(rdev), unix.Minor(rdev))
+
+	b, err := ioutil.ReadFile(filepath.Join(cgroupPath, "io.stat"))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	stats, ok := parseIOStat(b, key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s in %s", ErrNoIOStats, devName, cgroupPath)
+	}
+	stats.Time = now
+	return stats, nil
+}
+
+// parseIOStat returns the counters of the device with the MAJ:MIN key
+// from the contents of an io.stat file
+func parseIOStat(b []byte, key string) (*VolumeIO, bool) {
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != key {
+			continue
+		}
+		stats := &VolumeIO{}
+		for _, kv := range fields[1:] {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			v, err := strconv.ParseUint(parts[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch parts[0] {
+			case "rbytes":
+				stats.ReadBytes = v
+			case "wbytes":
+				stats.WriteBytes = v
+			case "rios":
+				stats.ReadIOs = v
+			case "wios":
+				stats.WriteIOs = v
+			}
+		}
+		return stats, true
+	}
+	return nil, false
+}
+
+// IORate is the I/O throughput between two VolumeIO samples
+type IORate struct {
+	ReadMBps  float64
+	WriteMBps float64
+	ReadIOPS  float64
+	WriteIOPS float64
+}
+
+// RateSince returns the throughput from prev up to v, with MB/s in units
+// of 10^6 bytes. Counters that went backwards, as they do when a device
+// is removed and added back, count as no I/O.
+func (v VolumeIO) RateSince(prev VolumeIO) IORate {
+	secs := v.Time.Sub(prev.Time).Seconds()
+	if secs <= 0 {
+		return IORate{}
+	}
+	delta := func(cur, old uint64) float64 {
+		if cur < old {
+			return 0
+		}
+		return float64(cur-old) / secs
+	}
+	return IORate{
+		ReadMBps:  delta(v.ReadBytes, prev.ReadBytes) / 1e6,
+		WriteMBps: delta(v.WriteBytes, prev.WriteBytes) / 1e6,
+		ReadIOPS:  delta(v.ReadIOs, prev.ReadIOs),
+		WriteIOPS: delta(v.WriteIOs, prev.WriteIOs),
+	}
+}
@@ -0,0 +1,97 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVolumeIOStats(t *testing.T) {
+	p := filepath.Join("devices", "pci0000:00", "block", "sda", "sda1")
+	fakeSysfs(t,
+		map[string]string{
+			filepath.Join(p, "partition"): "1\n",
+			// reads, merged, sectors, ms, writes, merged, sectors, ms, ...
+			filepath.Join(p, "stat"): "    1200      30    96000     500      800      10    64000     900        0     1400     1400        0        0        0        0\n",
+		},
+		map[string]string{
+			"class/block/sda1": filepath.Join("..", "..", p),
+		})
+
+	stats, err := VolumeIOStats("sda1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stats.Time = time.Time{}
+	expected := &VolumeIO{ReadIOs: 1200, ReadBytes: 96000 * 512, WriteIOs: 800, WriteBytes: 64000 * 512}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestParseIOStat(t *testing.T) {
+	ioStat := []byte("8:16 rbytes=1048576 wbytes=4096 rios=16 wios=1 dbytes=0 dios=0\n" +
+		"8:0 rbytes=2097152 wbytes=8388608 rios=32 wios=128 dbytes=0 dios=0\n")
+
+	stats, ok := parseIOStat(ioStat, "8:0")
+	if !ok {
+		t.Fatalf("8:0 not found")
+	}
+	expected := &VolumeIO{ReadBytes: 2097152, WriteBytes: 8388608, ReadIOs: 32, WriteIOs: 128}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+	if _, ok := parseIOStat(ioStat, "8:32"); ok {
+		t.Errorf("unexpected stats for 8:32")
+	}
+}
+
+func TestRateSince(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	prev := VolumeIO{ReadBytes: 0, WriteBytes: 10e6, ReadIOs: 100, WriteIOs: 500, Time: start}
+
+	testCases := []struct {
+		name string
+		cur  VolumeIO
+		rate IORate
+	}{
+		{
+			name: "steady",
+			cur:  VolumeIO{ReadBytes: 20e6, WriteBytes: 30e6, ReadIOs: 300, WriteIOs: 900, Time: start.Add(10 * time.Second)},
+			rate: IORate{ReadMBps: 2, WriteMBps: 2, ReadIOPS: 20, WriteIOPS: 40},
+		},
+		{
+			name: "counters reset",
+			cur:  VolumeIO{ReadBytes: 5e6, WriteBytes: 1e6, ReadIOs: 50, WriteIOs: 10, Time: start.Add(10 * time.Second)},
+			rate: IORate{ReadMBps: 0.5},
+		},
+		{
+			name: "same time",
+			cur:  VolumeIO{ReadBytes: 20e6, Time: start},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if rate := testCase.cur.RateSince(prev); rate != testCase.rate {
+				t.Errorf("expected %+v, got %+v", testCase.rate, rate)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// where the btrfs superblock keeps its fsid and label
+const (
+	btrfsFSIDOffset  = btrfsSuperblockOffset + 0x20
+	btrfsLabelOffset = btrfsSuperblockOffset + 0x12b
+	btrfsLabelSize   = 256
+)
+
+// maximum label lengths, in bytes
+var fsLabelSizes = map[FSType]int{
+	FSTypeEXT4:  16,
+	FSTypeXFS:   12,
+	FSTypeBtrfs: btrfsLabelSize - 1,
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// readFSIdentity reads the label and UUID straight from the superblock
+func readFSIdentity(devName string, fsType FSType) (string, string, error) {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	switch fsType {
+	case FSTypeEXT4:
+		sb, err := readEXT4Superblock(f)
+		if err != nil {
+			return "", "", err
+		}
+		return cString(sb.VolumeName[:]), formatUUID(sb.UUID), nil
+	case FSTypeXFS:
+		sb, err := readXFSSuperblock(f)
+		if err != nil {
+			return "", "", err
+		}
+		return cString(sb.FName[:]), formatUUID(sb.UUID), nil
+	case FSTypeBtrfs:
+		if !isBtrfs(f) {
+			return "", "", fmt.Errorf("no btrfs superblock found")
+		}
+		var fsid [16]byte
+		if _, err := f.ReadAt(fsid[:], btrfsFSIDOffset); err != nil {
+			return "", "", err
+		}
+		label := make([]byte, btrfsLabelSize)
+		if _, err := io.ReadFull(io.NewSectionReader(f, btrfsLabelOffset, btrfsLabelSize), label); err != nil {
+			return "", "", err
+		}
+		return cString(label), formatUUID(fsid), nil
+	}
+	return "", "", unsupported(fsType, "reading the label")
+}
+
+// refuseMounted fails for XFS and btrfs filesystems that are mounted,
+// whose tools only change the UUID offline
+func refuseMounted(devName string, fsType FSType) error {
+	if fsType == FSTypeEXT4 {
+		return nil
+	}
+	mounted, err := IsDeviceMounted(devName)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		return fmt.Errorf("%w: %s is mounted", ErrDeviceInUse, devName)
+	}
+	return nil
+}
+
+// SetFSLabel sets the label of the filesystem on devName and reads it
+// back from the superblock to confirm it took
+func SetFSLabel(devName string, fsType FSType, label string) error {
+	max, ok := fsLabelSizes[fsType]
+	if !ok {
+		return unsupported(fsType, "labelling")
+	}
+	if len(label) > max {
+		return fmt.Errorf("label %q is longer than the %d bytes %s allows", label, max, fsType)
+	}
+
+	device := getBlockFile(devName)
+	var cmd []string
+	switch fsType {
+	case FSTypeEXT4:
+		cmd = []string{"tune2fs", "-L", label, device}
+	case FSTypeXFS:
+		if err := refuseMounted(devName, fsType); err != nil {
+			return err
+		}
+		// xfs_admin takes "--" to clear the label
+		if label == "" {
+			label = "--"
+		}
+		cmd = []string{"xfs_admin", "-L", label, device}
+	case FSTypeBtrfs:
+		cmd = []string{"btrfs", "filesystem", "label", device, label}
+	}
+	if _, stderr, err := run(context.Background(), cmd[0], cmd[1:]...); err != nil {
+		return fmt.Errorf("could not label %s: %v: %s", devName, err, stderr)
+	}
+
+	got, _, err := readFSIdentity(devName, fsType)
+	if err != nil {
+		return err
+	}
+	if label == "--" {
+		label = ""
+	}
+	if got != label {
+		return fmt.Errorf("label of %s is %q after setting it to %q", devName, got, label)
+	}
+	return nil
+}
+
+// RegenerateFSUUID gives the filesystem on devName a new random UUID,
+// which resolves conflicts between cloned drives, and confirms the
+// change by reading the superblock back. XFS and btrfs filesystems must
+// not be mounted.
+func RegenerateFSUUID(devName string, fsType FSType) error {
+	device := getBlockFile(devName)
+	var cmd []string
+	switch fsType {
+	case FSTypeEXT4:
+		cmd = []string{"tune2fs", "-U", "random", device}
+	case FSTypeXFS:
+		cmd = []string{"xfs_admin", "-U", "generate", device}
+	case FSTypeBtrfs:
+		cmd = []string{"btrfstune", "-f", "-u", device}
+	default:
+		return unsupported(fsType, "changing the UUID")
+	}
+	if err := refuseMounted(devName, fsType); err != nil {
+		return err
+	}
+
+	_, before, err := readFSIdentity(devName, fsType)
+	if err != nil {
+		return err
+	}
+	if _, stderr, err := run(context.Background(), cmd[0], cmd[1:]...); err != nil {
+		return fmt.Errorf("could not change UUID of %s: %v: %s", devName, err, stderr)
+	}
+	_, after, err := readFSIdentity(devName, fsType)
+	if err != nil {
+		return err
+	}
+	if after == before {
+		return fmt.Errorf("UUID of %s is still %s", devName, before)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	ErrDeviceLocked = errors.New("device is locked by another process")
+	ErrDeviceInUse  = errors.New("device is in use")
+)
+
+// LockDevice takes an exclusive BSD lock on the device node of devName,
+// which is the convention udev and tools like systemd-repart follow for
+// block devices, so destructive operations do not race across processes.
+// The returned func releases the lock.
+func LockDevice(devName string) (func(), error) {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		f.Close()
+		if err == unix.EWOULDBLOCK {
+			return nil, fmt.Errorf("%w: %s", ErrDeviceLocked, devName)
+		}
+		return nil, fmt.Errorf("could not lock %s: %v", devName, err)
+	}
+
+	return func() {
+		if err := unix.Flock(int(f.Fd()), unix.LOCK_UN); err != nil {
+			glog.Errorf("could not unlock %s: %v", devName, err)
+		}
+		f.Close()
+	}, nil
+}
+
+// ExclusiveOpen opens devName with O_EXCL, which the kernel refuses while
+// the device or one of its partitions is mounted, or is held by device
+// mapper, md, bcache and the like. Unlike scanning mountinfo, this also
+// catches holders that are only visible inside the kernel.
+func ExclusiveOpen(devName string, flag int) (*os.File, error) {
+	f, err := openBlockFile(devName, flag|unix.O_EXCL)
+	if err != nil {
+		if errors.Is(err, unix.EBUSY) {
+			return nil, fmt.Errorf("%w: %s", ErrDeviceInUse, devName)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// CheckNotInUse fails with ErrDeviceInUse unless devName can be opened
+// exclusively. It is meant to run right before handing the device to a
+// tool that writes to it, which then takes its own exclusive open.
+func CheckNotInUse(devName string) error {
+	f, err := ExclusiveOpen(devName, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
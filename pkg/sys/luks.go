@@ -0,0 +1,201 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+type LUKSFormatOptions struct {
+	// Type is the LUKS version, luks2 if empty
+	Type       string
+	Cipher     string
+	KeySize    int
+	Hash       string
+	SectorSize int
+	// Force allows formatting over an existing filesystem or LUKS header;
+	// it is checked by GuardDestructive and not passed to cryptsetup
+	Force bool
+}
+
+func (o LUKSFormatOptions) args() []string {
+	luksType := o.Type
+	if luksType == "" {
+		luksType = "luks2"
+	}
+	args := []string{"--type", luksType}
+	if o.Cipher != "" {
+		args = append(args, "--cipher", o.Cipher)
+	}
+	if o.KeySize != 0 {
+		args = append(args, "--key-size", strconv.Itoa(o.KeySize))
+	}
+	if o.Hash != "" {
+		args = append(args, "--hash", o.Hash)
+	}
+	if o.SectorSize != 0 {
+		args = append(args, "--sector-size", strconv.Itoa(o.SectorSize))
+	}
+	return args
+}
+
+// cryptsetup runs cryptsetup with the key fed over stdin, so that it
+// never shows up in the process table or on disk
+func cryptsetup(key []byte, args ...string) error {
+	var stdin io.Reader
+	if key != nil {
+		stdin = bytes.NewReader(key)
+	}
+	if _, stderr, err := runWithStdin(context.Background(), stdin, "cryptsetup", args...); err != nil {
+		return fmt.Errorf("cryptsetup %s failed: %v: %s", args[0], err, stderr)
+	}
+	return nil
+}
+
+// FormatLUKS sets up LUKS encryption on devName with key, destroying any
+// data on it
+func FormatLUKS(devName string, key []byte, opts LUKSFormatOptions) error {
+	if len(key) == 0 {
+		return fmt.Errorf("empty LUKS key for %s", devName)
+	}
+	// the lock is held until cryptsetup is done, so nothing can claim the
+	// device between the checks and the format
+	unlock, err := LockDevice(devName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := GuardDestructive(devName, GuardOptions{Force: opts.Force}); err != nil {
+		return err
+	}
+	args := append([]string{"luksFormat", "--batch-mode", "--key-file=-"}, opts.args()...)
+	return cryptsetup(key, append(args, getBlockFile(devName))...)
+}
+
+// OpenLUKS unlocks devName as /dev/mapper/<mappedName> and returns the
+// path of the mapped device
+func OpenLUKS(devName, mappedName string, key []byte) (string, error) {
+	if err := cryptsetup(key, "open", "--type", "luks", "--key-file=-", getBlockFile(devName), mappedName); err != nil {
+		return "", err
+	}
+	return filepath.Join(DevRoot, "mapper", mappedName), nil
+}
+
+// CloseLUKS removes the /dev/mapper/<mappedName> mapping
+func CloseLUKS(mappedName string) error {
+	return cryptsetup(nil, "close", mappedName)
+}
+
+var (
+	luksMagic = []byte("LUKS\xba\xbe")
+
+	ErrNotLUKS = errors.New("not a LUKS device")
+)
+
+// both LUKS versions keep the magic, version and UUID at the same offsets
+const (
+	luksVersionOffset = 6
+	luksUUIDOffset    = 0xa8
+	luksUUIDSize      = 40
+)
+
+// LUKSHeader is what the LUKS header of a device identifies it by
+type LUKSHeader struct {
+	Version int
+	UUID    string
+}
+
+// ReadLUKSHeader reads the LUKS header of devName, returning ErrNotLUKS
+// if it has none
+func ReadLUKSHeader(devName string) (*LUKSHeader, error) {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b := make([]byte, luksUUIDOffset+luksUUIDSize)
+	if _, err := io.ReadFull(f, b); err != nil {
+		// devices too small for a header cannot hold one
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%w: %s", ErrNotLUKS, devName)
+		}
+		return nil, err
+	}
+	if !bytes.HasPrefix(b, luksMagic) {
+		return nil, fmt.Errorf("%w: %s", ErrNotLUKS, devName)
+	}
+	return &LUKSHeader{
+		Version: int(binary.BigEndian.Uint16(b[luksVersionOffset:])),
+		UUID:    cString(b[luksUUIDOffset:]),
+	}, nil
+}
+
+// EncryptedDrive is an unlocked LUKS device. Filesystem operations go to
+// Device, the mapped device, while the drive itself is known by Backing.
+type EncryptedDrive struct {
+	Backing    string
+	MappedName string
+	Header     LUKSHeader
+}
+
+// OpenEncryptedDrive unlocks devName as /dev/mapper/<mappedName>
+func OpenEncryptedDrive(devName, mappedName string, key []byte) (*EncryptedDrive, error) {
+	header, err := ReadLUKSHeader(devName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := OpenLUKS(devName, mappedName, key); err != nil {
+		return nil, err
+	}
+	return &EncryptedDrive{
+		Backing:    devName,
+		MappedName: mappedName,
+		Header:     *header,
+	}, nil
+}
+
+// Device returns the name of the mapped device, which every function of
+// this package taking a device name accepts
+func (e *EncryptedDrive) Device() string {
+	return filepath.Join("mapper", e.MappedName)
+}
+
+// ProbeFSType identifies the filesystem inside the encrypted drive
+func (e *EncryptedDrive) ProbeFSType() (FSType, error) {
+	return ProbeFSType(e.Device())
+}
+
+// Mount mounts the filesystem inside the encrypted drive at target
+func (e *EncryptedDrive) Mount(target string, fsType FSType, opts MountOptions) error {
+	return Mount(e.Device(), target, fsType, opts)
+}
+
+// Close locks the drive again by removing its mapping. It must be called
+// when the drive is released, after its filesystem has been unmounted;
+// device mapper refuses to remove a mapping that is still in use.
+func (e *EncryptedDrive) Close() error {
+	return CloseLUKS(e.MappedName)
+}
@@ -0,0 +1,264 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+// MigrateProgressFunc is called as data is copied during a migration
+type MigrateProgressFunc func(copied, total uint64)
+
+type MigrateOptions struct {
+	Progress MigrateProgressFunc
+	// PreserveSecurityXattrs copies security.* extended attributes, such
+	// as SELinux labels, in addition to user.* ones
+	PreserveSecurityXattrs bool
+	// IOPriority of the copy, defaulting to BackgroundIOPriority
+	IOPriority IOPriority
+}
+
+const migratePrefix = ".migrate-"
+
+// whence values for lseek(2) that skip over holes
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+// MigrateVolume copies the volume at srcPath to dstDrive/volumeID,
+// preserving holes, ownership, modes, timestamps and extended attributes,
+// and verifies the copy with VerifyTree. Data is copied into a staging
+// directory on dstDrive that is renamed into place only once everything
+// has been copied, so an interrupted migration can be resumed by calling
+// MigrateVolume again.
+// The source is left untouched. The path of the new volume is returned.
+func MigrateVolume(ctx context.Context, srcPath, dstDrive, volumeID string, opts MigrateOptions) (string, error) {
+	dstPath := filepath.Join(dstDrive, volumeID)
+	if _, err := os.Lstat(dstPath); err == nil {
+		return "", fmt.Errorf("volume %s already exists on %s", volumeID, dstDrive)
+	}
+
+	var total uint64
+	err := filepath.Walk(srcPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			total += uint64(info.Size())
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	copyXattrs := CopyXattrs
+	if opts.PreserveSecurityXattrs {
+		copyXattrs = CopySecurityXattrs
+	}
+	xattrsSupported := true
+
+	staging := filepath.Join(dstDrive, migratePrefix+volumeID)
+	var copied uint64
+	err = withIOPriority(opts.IOPriority, func() error {
+		return filepath.Walk(srcPath, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(srcPath, p)
+			if err != nil {
+				return err
+			}
+			if err := migrateEntry(ctx, p, filepath.Join(staging, rel), info); err != nil {
+				return fmt.Errorf("could not migrate %s: %v", p, err)
+			}
+			if xattrsSupported && info.Mode()&os.ModeSymlink == 0 {
+				err := copyXattrs(p, filepath.Join(staging, rel))
+				if errors.Is(err, ErrXattrsUnsupported) {
+					glog.Warningf("extended attributes of volume %s are not preserved: %v", volumeID, err)
+					xattrsSupported = false
+				} else if err != nil {
+					return err
+				}
+			}
+			if info.Mode().IsRegular() {
+				copied += uint64(info.Size())
+				if opts.Progress != nil {
+					opts.Progress(copied, total)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// directory timestamps change as entries are added, so set them last
+	err = filepath.Walk(srcPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(srcPath, p)
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(filepath.Join(staging, rel), info.ModTime(), info.ModTime())
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ok, mismatched, err := VerifyTree(srcPath, staging)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("migrated volume %s does not match its source: %s", volumeID, strings.Join(mismatched, ", "))
+	}
+
+	if err := os.Rename(staging, dstPath); err != nil {
+		return "", err
+	}
+	glog.V(5).Infof("migrated volume %s from %s to %s", volumeID, srcPath, dstPath)
+	return dstPath, nil
+}
+
+func migrateEntry(ctx context.Context, src, dst string, info os.FileInfo) error {
+	st, ok := info.Sys().(*unix.Stat_t)
+	if !ok {
+		st = &unix.Stat_t{}
+		if err := unix.Lstat(src, st); err != nil {
+			return err
+		}
+	}
+
+	switch mode := info.Mode(); {
+	case mode.IsDir():
+		if err := os.MkdirAll(dst, 0700); err != nil {
+			return err
+		}
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Symlink(target, dst); err != nil {
+			return err
+		}
+		return os.Lchown(dst, int(st.Uid), int(st.Gid))
+	case mode.IsRegular():
+		// files left behind by an earlier attempt are only complete once
+		// their timestamps have been copied over
+		if dstInfo, err := os.Lstat(dst); err == nil &&
+			dstInfo.Size() == info.Size() && dstInfo.ModTime().Equal(info.ModTime()) {
+			return nil
+		}
+		if err := copyFile(ctx, src, dst, info.Size()); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported file type %v", mode)
+	}
+
+	if err := os.Lchown(dst, int(st.Uid), int(st.Gid)); err != nil {
+		return err
+	}
+	if err := os.Chmod(dst, info.Mode()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// copyFile copies only the data regions of src, leaving holes in dst
+func copyFile(ctx context.Context, src, dst string, size int64) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var offset int64
+	for offset < size {
+		data, err := in.Seek(offset, seekData)
+		if err != nil {
+			if errors.Is(err, unix.ENXIO) {
+				// only a hole is left
+				break
+			}
+			if errors.Is(err, unix.EINVAL) {
+				// no SEEK_DATA support, copy everything
+				data = offset
+			} else {
+				return err
+			}
+		}
+		hole, err := in.Seek(data, seekHole)
+		if err != nil {
+			hole = size
+		}
+		if _, err := in.Seek(data, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := out.Seek(data, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(out, ctxReader{ctx, in}, hole-data); err != nil {
+			return err
+		}
+		offset = hole
+	}
+
+	if err := out.Truncate(size); err != nil {
+		return err
+	}
+	return out.Sync()
+}
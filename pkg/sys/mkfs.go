@@ -0,0 +1,124 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+)
+
+const ext4BlockSize = 4096
+
+// DriveGeometry is what mkfs flags are derived from
+type DriveGeometry struct {
+	Size           uint64
+	LogicalSector  uint64
+	PhysicalSector uint64
+	// MinimumIO and OptimalIO are the RAID chunk and stripe sizes as
+	// reported in sysfs, zero when the device does not report them
+	MinimumIO uint64
+	OptimalIO uint64
+}
+
+// ReadDriveGeometry reads the DriveGeometry of devName from sysfs
+func ReadDriveGeometry(devName string) (*DriveGeometry, error) {
+	size, err := DeviceSize(devName)
+	if err != nil {
+		return nil, err
+	}
+	logical, physical, _, err := SectorType(devName)
+	if err != nil {
+		return nil, err
+	}
+	minIO, err := readQueueUint(devName, "minimum_io_size")
+	if err != nil {
+		return nil, err
+	}
+	optIO, err := readQueueUint(devName, "optimal_io_size")
+	if err != nil {
+		return nil, err
+	}
+	return &DriveGeometry{
+		Size:           size,
+		LogicalSector:  logical,
+		PhysicalSector: physical,
+		MinimumIO:      minIO,
+		OptimalIO:      optIO,
+	}, nil
+}
+
+// striped reports whether the device advertises a RAID stripe, i.e. a
+// chunk size and a stripe width that is a whole number of chunks. Plain
+// 512e drives report their physical sector as minimum_io_size, so only a
+// larger chunk counts.
+func (g DriveGeometry) striped() bool {
+	return g.MinimumIO > g.PhysicalSector && g.OptimalIO > g.MinimumIO && g.OptimalIO%g.MinimumIO == 0
+}
+
+// MkfsArgs returns the mkfs flags suited to a device of geometry g.
+// Device and filesystem type are left out so the flags can be shown to
+// operators before anything is formatted.
+func MkfsArgs(fsType FSType, g DriveGeometry) ([]string, error) {
+	args := []string{}
+	switch fsType {
+	case FSTypeEXT4:
+		args = append(args, "-b", fmt.Sprint(ext4BlockSize))
+		// 32bit block numbers run out at 16TiB with 4KiB blocks
+		if g.Size >= 16*TiB {
+			args = append(args, "-O", "64bit")
+		}
+		// large drives hold large objects, so one inode per 16KiB
+		// wastes space on inode tables that are never used
+		if g.Size >= TiB {
+			args = append(args, "-i", fmt.Sprint(MiB))
+		}
+		if g.striped() && g.MinimumIO%ext4BlockSize == 0 {
+			stride := g.MinimumIO / ext4BlockSize
+			args = append(args, "-E", fmt.Sprintf("stride=%d,stripe_width=%d", stride, stride*(g.OptimalIO/g.MinimumIO)))
+		}
+	case FSTypeXFS:
+		if g.LogicalSector > 512 {
+			args = append(args, "-s", fmt.Sprintf("size=%d", g.LogicalSector))
+		}
+		if g.striped() {
+			args = append(args, "-d", fmt.Sprintf("su=%d,sw=%d", g.MinimumIO, g.OptimalIO/g.MinimumIO))
+		}
+	case FSTypeBtrfs:
+		// mkfs.btrfs picks its sector size from the page size and reads
+		// the stripe geometry itself
+	default:
+		return nil, unsupported(fsType, "mkfs")
+	}
+	return args, nil
+}
+
+// MkfsCommand returns the complete mkfs command line for formatting
+// devName with fsType, for dry runs and for review
+func MkfsCommand(devName string, fsType FSType) ([]string, error) {
+	g, err := ReadDriveGeometry(devName)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateFormatSize(fsType, g.Size); err != nil {
+		return nil, err
+	}
+	args, err := MkfsArgs(fsType, *g)
+	if err != nil {
+		return nil, err
+	}
+	cmd := append([]string{"mkfs." + string(fsType)}, args...)
+	return append(cmd, getBlockFile(devName)), nil
+}
@@ -0,0 +1,92 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMkfsArgs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fsType   FSType
+		geometry DriveGeometry
+		striped  bool
+		args     []string
+	}{
+		{
+			name:     "512n hdd",
+			fsType:   FSTypeXFS,
+			geometry: DriveGeometry{Size: TiB, LogicalSector: 512, PhysicalSector: 512, MinimumIO: 512},
+			args:     []string{},
+		},
+		{
+			name:     "512e hdd reporting an optimal io size",
+			fsType:   FSTypeXFS,
+			geometry: DriveGeometry{Size: 4 * TiB, LogicalSector: 512, PhysicalSector: 4096, MinimumIO: 4096, OptimalIO: 1048576},
+			args:     []string{},
+		},
+		{
+			name:     "512e ssd on ext4",
+			fsType:   FSTypeEXT4,
+			geometry: DriveGeometry{Size: 512 * GiB, LogicalSector: 512, PhysicalSector: 4096, MinimumIO: 4096, OptimalIO: 131072},
+			args:     []string{"-b", "4096"},
+		},
+		{
+			name:     "4Kn hdd",
+			fsType:   FSTypeXFS,
+			geometry: DriveGeometry{Size: 8 * TiB, LogicalSector: 4096, PhysicalSector: 4096, MinimumIO: 4096, OptimalIO: 65536},
+			args:     []string{"-s", "size=4096"},
+		},
+		{
+			name:     "md raid on 512e drives",
+			fsType:   FSTypeXFS,
+			geometry: DriveGeometry{Size: 16 * TiB, LogicalSector: 512, PhysicalSector: 4096, MinimumIO: 524288, OptimalIO: 2097152},
+			striped:  true,
+			args:     []string{"-d", "su=524288,sw=4"},
+		},
+		{
+			name:     "md raid on 4Kn drives with ext4",
+			fsType:   FSTypeEXT4,
+			geometry: DriveGeometry{Size: 16 * TiB, LogicalSector: 4096, PhysicalSector: 4096, MinimumIO: 65536, OptimalIO: 196608},
+			striped:  true,
+			args:     []string{"-b", "4096", "-O", "64bit", "-i", "1048576", "-E", "stride=16,stripe_width=48"},
+		},
+		{
+			name:     "optimal io not a multiple of the chunk",
+			fsType:   FSTypeXFS,
+			geometry: DriveGeometry{Size: TiB, LogicalSector: 512, PhysicalSector: 512, MinimumIO: 65536, OptimalIO: 100000},
+			args:     []string{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if striped := testCase.geometry.striped(); striped != testCase.striped {
+				t.Errorf("striped: expected %v, got %v", testCase.striped, striped)
+			}
+			args, err := MkfsArgs(testCase.fsType, testCase.geometry)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(args, testCase.args) {
+				t.Errorf("expected %v, got %v", testCase.args, args)
+			}
+		})
+	}
+}
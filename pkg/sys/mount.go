@@ -0,0 +1,138 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/golang/glog"
+	"k8s.io/utils/mount"
+)
+
+// ErrorBehavior is what ext4 does when it detects on-disk corruption
+type ErrorBehavior string
+
+const (
+	ErrorBehaviorRemountRO ErrorBehavior = "remount-ro"
+	ErrorBehaviorPanic     ErrorBehavior = "panic"
+	ErrorBehaviorContinue  ErrorBehavior = "continue"
+
+	DefaultErrorBehavior = ErrorBehaviorRemountRO
+)
+
+func (e ErrorBehavior) Validate() error {
+	switch e {
+	case ErrorBehaviorRemountRO, ErrorBehaviorPanic, ErrorBehaviorContinue:
+		return nil
+	}
+	return fmt.Errorf("invalid error behavior %q", e)
+}
+
+func (e ErrorBehavior) MountOption() string {
+	return "errors=" + string(e)
+}
+
+// SetErrorBehavior persists the error behavior in the ext4 superblock so
+// it applies even when the filesystem is mounted without errors=
+func SetErrorBehavior(devName string, e ErrorBehavior) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	_, stderr, err := run(context.Background(), "tune2fs", "-e", string(e), getBlockFile(devName))
+	if err != nil {
+		return fmt.Errorf("could not set error behavior on %s: %v: %s", devName, err, stderr)
+	}
+	return nil
+}
+
+type MountOptions struct {
+	ReadOnly bool
+	Options  []string
+	// ErrorBehavior only applies to ext4, defaulting to remount-ro
+	ErrorBehavior ErrorBehavior
+	// Quota only applies to XFS and ext4, which are then verified to be
+	// enforcing it once mounted
+	Quota QuotaMode
+}
+
+// mountFlags returns the options fsType is mounted with under opts
+func mountFlags(fsType FSType, opts MountOptions) (MountFlags, error) {
+	flags := ParseMountFlags(opts.Options)
+	if opts.ReadOnly {
+		flags.Parse([]string{"ro"})
+	}
+	if fsType == FSTypeEXT4 {
+		// errors= given in Options is kept unless ErrorBehavior is set
+		e := opts.ErrorBehavior
+		if e == "" && !flags.hasKey("errors") {
+			e = DefaultErrorBehavior
+		}
+		if e != "" {
+			if err := e.Validate(); err != nil {
+				return MountFlags{}, err
+			}
+			flags.Parse([]string{e.MountOption()})
+		}
+	}
+	if opts.Quota != QuotaNone {
+		if !supportsQuota(fsType) {
+			return MountFlags{}, unsupported(fsType, string(opts.Quota)+" quota")
+		}
+		o, err := opts.Quota.MountOption()
+		if err != nil {
+			return MountFlags{}, err
+		}
+		flags.Parse([]string{o})
+	}
+	return flags, nil
+}
+
+// Mount mounts the filesystem on devName at target
+func Mount(devName, target string, fsType FSType, opts MountOptions) error {
+	flags, err := mountFlags(fsType, opts)
+	if err != nil {
+		return err
+	}
+	options := flags.Options()
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	glog.V(5).Infof("mounting %s at %s with options %v", devName, target, options)
+	mounter := mount.New("")
+	if err := mounter.Mount(getBlockFile(devName), target, string(fsType), options); err != nil {
+		return err
+	}
+	if _, err := MountOptionDrift(target, opts.Options); err != nil {
+		glog.V(5).Infof("could not check mount options of %s: %v", target, err)
+	}
+	if opts.Quota == QuotaNone {
+		return nil
+	}
+
+	// ext4 without the quota feature, or a kernel without quota support,
+	// accepts the option but enforces nothing
+	if err := CheckQuotaActive(devName, opts.Quota); err != nil {
+		if uerr := mounter.Unmount(target); uerr != nil {
+			glog.Errorf("could not unmount %s: %v", target, uerr)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMountFlagsErrorBehavior(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fsType  FSType
+		opts    MountOptions
+		options []string
+	}{
+		{
+			name:    "ext4 default",
+			fsType:  FSTypeEXT4,
+			opts:    MountOptions{Options: []string{"noatime"}},
+			options: []string{"noatime", "errors=remount-ro"},
+		},
+		{
+			name:    "ext4 errors given in options",
+			fsType:  FSTypeEXT4,
+			opts:    MountOptions{Options: []string{"noatime,errors=panic"}},
+			options: []string{"noatime", "errors=panic"},
+		},
+		{
+			name:    "ext4 error behavior overrides options",
+			fsType:  FSTypeEXT4,
+			opts:    MountOptions{Options: []string{"errors=panic"}, ErrorBehavior: ErrorBehaviorContinue},
+			options: []string{"errors=continue"},
+		},
+		{
+			name:    "ext4 read-only",
+			fsType:  FSTypeEXT4,
+			opts:    MountOptions{ReadOnly: true, ErrorBehavior: ErrorBehaviorPanic},
+			options: []string{"ro", "errors=panic"},
+		},
+		{
+			name:    "xfs has no error behavior",
+			fsType:  FSTypeXFS,
+			opts:    MountOptions{Options: []string{"noatime"}},
+			options: []string{"noatime"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			flags, err := mountFlags(testCase.fsType, testCase.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if options := flags.Options(); !reflect.DeepEqual(options, testCase.options) {
+				t.Errorf("expected %v, got %v", testCase.options, options)
+			}
+		})
+	}
+
+	if _, err := mountFlags(FSTypeEXT4, MountOptions{ErrorBehavior: "reboot"}); err == nil {
+		t.Errorf("expected invalid error behavior to fail")
+	}
+}
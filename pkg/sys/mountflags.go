@@ -0,0 +1,227 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+var perMountFlags = map[string]uintptr{
+	"ro":          unix.MS_RDONLY,
+	"rw":          0,
+	"nosuid":      unix.MS_NOSUID,
+	"suid":        0,
+	"nodev":       unix.MS_NODEV,
+	"dev":         0,
+	"noexec":      unix.MS_NOEXEC,
+	"exec":        0,
+	"noatime":     unix.MS_NOATIME,
+	"relatime":    unix.MS_RELATIME,
+	"strictatime": unix.MS_STRICTATIME,
+	"nodiratime":  unix.MS_NODIRATIME,
+	"diratime":    0,
+	"sync":        unix.MS_SYNCHRONOUS,
+	"async":       0,
+}
+
+// options naming the kind of mount call rather than a property of the
+// mount, which mountinfo never shows
+var operationFlags = map[string]uintptr{
+	"bind":    unix.MS_BIND,
+	"rbind":   unix.MS_BIND | unix.MS_REC,
+	"remount": unix.MS_REMOUNT,
+}
+
+// options that cancel each other out
+var conflictingOptions = map[string][]string{
+	"ro":          {"rw"},
+	"rw":          {"ro"},
+	"nosuid":      {"suid"},
+	"suid":        {"nosuid"},
+	"nodev":       {"dev"},
+	"dev":         {"nodev"},
+	"noexec":      {"exec"},
+	"exec":        {"noexec"},
+	"noatime":     {"relatime", "strictatime"},
+	"relatime":    {"noatime", "strictatime"},
+	"strictatime": {"noatime", "relatime"},
+	"nodiratime":  {"diratime"},
+	"diratime":    {"nodiratime"},
+	"sync":        {"async"},
+	"async":       {"sync"},
+	"bind":        {"rbind"},
+	"rbind":       {"bind"},
+}
+
+// defaults expands to the options mount(8) takes it for, less auto and
+// nouser, which only mean something in fstab
+var defaultOptions = []string{"rw", "suid", "dev", "exec", "async"}
+
+// MountFlags splits mount options into those the kernel takes as MS_*
+// flags and the filesystem specific rest, which is passed as data
+type MountFlags struct {
+	flags []string
+	data  []string
+}
+
+// ParseMountFlags returns the MountFlags of options
+func ParseMountFlags(options []string) MountFlags {
+	f := MountFlags{}
+	f.Parse(options)
+	return f
+}
+
+func remove(options []string, o string) []string {
+	kept := options[:0]
+	for _, k := range options {
+		if k != o {
+			kept = append(kept, k)
+		}
+	}
+	return kept
+}
+
+// splitOptions splits comma separated options, leaving commas within
+// double quotes alone, as in context="system_u:object_r:svirt_t:s0:c1,c2"
+func splitOptions(option string) []string {
+	options := []string{}
+	quoted := false
+	start := 0
+	for i, c := range option {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				options = append(options, option[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(options, option[start:])
+}
+
+// optionKey returns the key of key=value options, or o itself
+func optionKey(o string) string {
+	return strings.SplitN(o, "=", 2)[0]
+}
+
+func removeKey(options []string, key string) []string {
+	kept := options[:0]
+	for _, k := range options {
+		if optionKey(k) != key {
+			kept = append(kept, k)
+		}
+	}
+	return kept
+}
+
+// Parse adds options, which may be comma separated, to f. An option
+// replaces any earlier one it conflicts with, so that e.g. a later ro
+// wins over an earlier rw, and a later errors=panic over an earlier
+// errors=remount-ro; repeated options are kept once. defaults is
+// expanded in place, so options after it still override it.
+func (f *MountFlags) Parse(options []string) {
+	for _, option := range options {
+		for _, o := range splitOptions(option) {
+			switch o {
+			case "":
+				continue
+			case "defaults":
+				f.Parse(defaultOptions)
+				continue
+			}
+			_, isFlag := perMountFlags[o]
+			if _, ok := operationFlags[o]; ok {
+				isFlag = true
+			}
+			if !isFlag {
+				f.data = append(removeKey(f.data, optionKey(o)), o)
+				continue
+			}
+			for _, c := range conflictingOptions[o] {
+				f.flags = remove(f.flags, c)
+			}
+			f.flags = append(remove(f.flags, o), o)
+		}
+	}
+}
+
+// Has reports whether option o is set. Options that clear a flag, such
+// as suid or exec, are also set when the flag they clear is not, since
+// mountinfo does not list them.
+func (f MountFlags) Has(o string) bool {
+	for _, k := range append(append([]string{}, f.flags...), f.data...) {
+		if k == o {
+			return true
+		}
+	}
+	if flag, ok := perMountFlags[o]; ok && flag == 0 {
+		for _, c := range conflictingOptions[o] {
+			if f.has(c) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// hasKey reports whether a filesystem option with the given key, as in
+// key=value, is set
+func (f MountFlags) hasKey(key string) bool {
+	for _, k := range f.data {
+		if optionKey(k) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (f MountFlags) has(o string) bool {
+	for _, k := range f.flags {
+		if k == o {
+			return true
+		}
+	}
+	return false
+}
+
+// Flags returns the MS_* bitmask for mount(2)
+func (f MountFlags) Flags() uintptr {
+	var flags uintptr
+	for _, o := range f.flags {
+		flags |= perMountFlags[o] | operationFlags[o]
+	}
+	return flags
+}
+
+// Data returns the filesystem specific options for mount(2)
+func (f MountFlags) Data() string {
+	return strings.Join(f.data, ",")
+}
+
+// Options returns all options, flags first
+func (f MountFlags) Options() []string {
+	return append(append([]string{}, f.flags...), f.data...)
+}
+
+func (f MountFlags) String() string {
+	return strings.Join(f.Options(), ",")
+}
@@ -0,0 +1,136 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestParseMountFlags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options []string
+		flags   uintptr
+		data    string
+		all     []string
+	}{
+		{
+			name:    "flags and data",
+			options: []string{"ro,noatime", "discard"},
+			flags:   unix.MS_RDONLY | unix.MS_NOATIME,
+			data:    "discard",
+			all:     []string{"ro", "noatime", "discard"},
+		},
+		{
+			name:    "later flag wins",
+			options: []string{"ro", "nosuid", "rw"},
+			flags:   unix.MS_NOSUID,
+			all:     []string{"nosuid", "rw"},
+		},
+		{
+			name:    "later value wins per key",
+			options: []string{"errors=remount-ro,discard", "errors=panic"},
+			data:    "discard,errors=panic",
+			all:     []string{"discard", "errors=panic"},
+		},
+		{
+			name:    "repeated options kept once",
+			options: []string{"discard,noatime", "discard", "noatime"},
+			flags:   unix.MS_NOATIME,
+			data:    "discard",
+			all:     []string{"noatime", "discard"},
+		},
+		{
+			name:    "defaults expanded",
+			options: []string{"defaults"},
+			all:     []string{"rw", "suid", "dev", "exec", "async"},
+		},
+		{
+			name:    "options after defaults override it",
+			options: []string{"nosuid", "defaults", "ro,noexec"},
+			flags:   unix.MS_RDONLY | unix.MS_NOEXEC,
+			all:     []string{"suid", "dev", "async", "ro", "noexec"},
+		},
+		{
+			name:    "operation flags",
+			options: []string{"bind", "rbind"},
+			flags:   unix.MS_BIND | unix.MS_REC,
+			all:     []string{"rbind"},
+		},
+		{
+			name:    "quoted selinux context with a category list",
+			options: []string{`nosuid,context="system_u:object_r:container_file_t:s0:c1,c2",discard`},
+			flags:   unix.MS_NOSUID,
+			data:    `context="system_u:object_r:container_file_t:s0:c1,c2",discard`,
+			all:     []string{"nosuid", `context="system_u:object_r:container_file_t:s0:c1,c2"`, "discard"},
+		},
+		{
+			name:    "later selinux context wins",
+			options: []string{`context="system_u:object_r:container_file_t:s0:c1,c2"`, `context="system_u:object_r:container_file_t:s0:c3,c4"`},
+			data:    `context="system_u:object_r:container_file_t:s0:c3,c4"`,
+			all:     []string{`context="system_u:object_r:container_file_t:s0:c3,c4"`},
+		},
+		{
+			name:    "empty options",
+			options: []string{"", ",,"},
+			all:     []string{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			f := ParseMountFlags(testCase.options)
+			if flags := f.Flags(); flags != testCase.flags {
+				t.Errorf("expected flags %#x, got %#x", testCase.flags, flags)
+			}
+			if data := f.Data(); data != testCase.data {
+				t.Errorf("expected data %q, got %q", testCase.data, data)
+			}
+			if all := f.Options(); !reflect.DeepEqual(all, testCase.all) {
+				t.Errorf("expected options %v, got %v", testCase.all, all)
+			}
+		})
+	}
+}
+
+func TestMountFlagsHas(t *testing.T) {
+	// as mountinfo lists a nosuid mount of an ext4 filesystem
+	f := ParseMountFlags([]string{"rw,nosuid,relatime", "errors=remount-ro"})
+	testCases := []struct {
+		option string
+		has    bool
+	}{
+		{"rw", true},
+		{"nosuid", true},
+		{"suid", false},
+		{"exec", true},
+		{"dev", true},
+		{"noexec", false},
+		{"ro", false},
+		{"errors=remount-ro", true},
+		{"errors=panic", false},
+		{"discard", false},
+	}
+	for _, testCase := range testCases {
+		if has := f.Has(testCase.option); has != testCase.has {
+			t.Errorf("%s: expected %v, got %v", testCase.option, testCase.has, has)
+		}
+	}
+}
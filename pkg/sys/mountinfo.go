@@ -0,0 +1,222 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+	"k8s.io/utils/mount"
+)
+
+func readMountInfo() ([]mount.MountInfo, error) {
+	return mount.ParseMountInfo(filepath.Join(ProcRoot, "self", "mountinfo"))
+}
+
+type MountedFS struct {
+	MountPoint string
+	Source     string
+	FSType     FSType
+	Options    []string
+	TotalBytes uint64
+	FreeBytes  uint64
+}
+
+// network filesystems are classified from mountinfo alone, since statfs
+// on an unreachable server can block indefinitely
+func isNetworkFS(fsType string) bool {
+	switch fsType {
+	case "nfs", "nfs4", "cifs", "smb3", "ceph", "glusterfs":
+		return true
+	}
+	return strings.HasPrefix(fsType, "fuse")
+}
+
+// ListFilesystemMounts lists every mount on the node, pseudo filesystems
+// included, typed by their statfs magic
+func ListFilesystemMounts() ([]MountedFS, error) {
+	infos, err := readMountInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := []MountedFS{}
+	for _, info := range infos {
+		m := MountedFS{
+			MountPoint: info.MountPoint,
+			Source:     info.Source,
+			FSType:     FSType(info.FsType),
+			Options:    info.MountOptions,
+		}
+		if !isNetworkFS(info.FsType) {
+			var st unix.Statfs_t
+			if err := unix.Statfs(info.MountPoint, &st); err != nil {
+				glog.V(5).Infof("could not statfs %s: %v", info.MountPoint, err)
+			} else {
+				if fsType, ok := fsMagics[int64(st.Type)]; ok {
+					m.FSType = fsType
+				}
+				m.TotalBytes = st.Blocks * uint64(st.Bsize)
+				m.FreeBytes = st.Bavail * uint64(st.Bsize)
+			}
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+// IsDiskMounted reports whether the disk holding devName, or any of its
+// partitions, is mounted
+func IsDiskMounted(devName string) (bool, error) {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return false, err
+	}
+	infos, err := readMountInfo()
+	if err != nil {
+		return false, err
+	}
+	for _, info := range infos {
+		if info.Major == 0 {
+			// anonymous devices back pseudo filesystems
+			continue
+		}
+		name, err := deviceNameByNumber(fmt.Sprintf("%d:%d", info.Major, info.Minor))
+		if err != nil {
+			continue
+		}
+		if mountedDisk, err := ParentDisk(name); err == nil && mountedDisk == disk {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IsDeviceMounted reports whether the filesystem on devName itself is
+// mounted. btrfs mounts carry an anonymous device number, so mounts are
+// matched by their source as well.
+func IsDeviceMounted(devName string) (bool, error) {
+	name := blockName(devName)
+	infos, err := readMountInfo()
+	if err != nil {
+		return false, err
+	}
+	for _, info := range infos {
+		if info.Major != 0 {
+			if n, err := deviceNameByNumber(fmt.Sprintf("%d:%d", info.Major, info.Minor)); err == nil && n == name {
+				return true, nil
+			}
+		}
+		if strings.HasPrefix(info.Source, "/dev/") && blockName(info.Source) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func findMount(mountpoint string) (*mount.MountInfo, error) {
+	infos, err := readMountInfo()
+	if err != nil {
+		return nil, err
+	}
+	// the last entry wins when mounts are stacked on the same path
+	var found *mount.MountInfo
+	for i := range infos {
+		if infos[i].MountPoint == mountpoint {
+			found = &infos[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s is not a mount point", mountpoint)
+	}
+	return found, nil
+}
+
+// findMountOf returns the mount the path lives on, which is the one with
+// the longest mount point containing it
+func findMountOf(path string) (*mount.MountInfo, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := readMountInfo()
+	if err != nil {
+		return nil, err
+	}
+	var found *mount.MountInfo
+	for i := range infos {
+		mp := infos[i].MountPoint
+		if path != mp && mp != "/" && !strings.HasPrefix(path, mp+"/") {
+			continue
+		}
+		if found == nil || len(mp) >= len(found.MountPoint) {
+			found = &infos[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no mount found for %s", path)
+	}
+	return found, nil
+}
+
+// EffectiveMountOptions returns the options in effect on the mount at
+// mountpoint, both the per-mount ones, such as ro and nosuid, and the
+// filesystem ones, such as discard. Options the kernel or filesystem
+// did not accept are missing, even though the mount succeeded.
+func EffectiveMountOptions(mountpoint string) ([]string, error) {
+	info, err := findMount(mountpoint)
+	if err != nil {
+		return nil, err
+	}
+	return mountInfoFlags(info).Options(), nil
+}
+
+// mountInfoFlags merges the per-mount and superblock options of a mount.
+// Both list rw or ro, and the mount is read-only if either says so.
+func mountInfoFlags(info *mount.MountInfo) MountFlags {
+	f := ParseMountFlags(info.SuperOptions)
+	readOnly := f.has("ro")
+	f.Parse(info.MountOptions)
+	if readOnly {
+		f.Parse([]string{"ro"})
+	}
+	return f
+}
+
+// MountOptionDrift returns the requested options not in effect on the
+// mount at mountpoint and logs a warning if there are any
+func MountOptionDrift(mountpoint string, requested []string) ([]string, error) {
+	effective, err := EffectiveMountOptions(mountpoint)
+	if err != nil {
+		return nil, err
+	}
+	active := ParseMountFlags(effective)
+
+	missing := []string{}
+	for _, o := range ParseMountFlags(requested).Options() {
+		if _, ok := operationFlags[o]; !ok && !active.Has(o) {
+			missing = append(missing, o)
+		}
+	}
+	if len(missing) > 0 {
+		glog.Warningf("mount options %v requested on %s are not in effect", missing, mountpoint)
+	}
+	return missing, nil
+}
@@ -0,0 +1,93 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testMountInfo = `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw,errors=remount-ro
+40 22 8:16 / /var/lib/direct-csi/mnt/sdb rw,noatime shared:20 - xfs /dev/sdb rw,attr2,inode64,logbufs=8,noquota
+41 22 8:16 /pvc-1 /var/lib/kubelet/pods/1/volumes/pvc-1/mount ro,nosuid,nodev,noatime shared:20 - xfs /dev/sdb rw,attr2,inode64,logbufs=8,noquota
+42 22 0:45 / /var/lib/direct-csi/mnt/sdc rw,relatime shared:21 - btrfs /dev/sdc rw,space_cache,subvolid=5,subvol=/
+`
+
+func TestEffectiveMountOptions(t *testing.T) {
+	fakeProcRoot(t, "self", testMountInfo)
+
+	options, err := EffectiveMountOptions("/var/lib/kubelet/pods/1/volumes/pvc-1/mount")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"ro", "nosuid", "nodev", "noatime", "attr2", "inode64", "logbufs=8", "noquota"}
+	if !reflect.DeepEqual(options, expected) {
+		t.Errorf("expected %v, got %v", expected, options)
+	}
+
+	if _, err := EffectiveMountOptions("/var/lib/kubelet"); err == nil {
+		t.Errorf("expected error for a path that is not a mount point")
+	}
+}
+
+func TestMountOptionDrift(t *testing.T) {
+	fakeProcRoot(t, "self", testMountInfo)
+
+	testCases := []struct {
+		name      string
+		requested []string
+		missing   []string
+	}{
+		{"all in effect", []string{"noatime", "inode64"}, []string{}},
+		{"defaults in effect", []string{"defaults"}, []string{}},
+		{"operation flags ignored", []string{"bind", "noatime"}, []string{}},
+		{"dropped by the filesystem", []string{"noatime", "discard", "logbufs=4"}, []string{"discard", "logbufs=4"}},
+		{"per-mount flag not set", []string{"nodev"}, []string{"nodev"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			missing, err := MountOptionDrift("/var/lib/direct-csi/mnt/sdb", testCase.requested)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(missing, testCase.missing) {
+				t.Errorf("expected %v, got %v", testCase.missing, missing)
+			}
+		})
+	}
+}
+
+func TestIsBindMount(t *testing.T) {
+	fakeProcRoot(t, "self", testMountInfo)
+
+	testCases := []struct {
+		mountpoint string
+		bind       bool
+	}{
+		{"/var/lib/direct-csi/mnt/sdb", false},
+		{"/var/lib/kubelet/pods/1/volumes/pvc-1/mount", true},
+	}
+	for _, testCase := range testCases {
+		info, err := findMount(testCase.mountpoint)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bind := isBindMount(info); bind != testCase.bind {
+			t.Errorf("%s: expected bind mount %v, got %v", testCase.mountpoint, testCase.bind, bind)
+		}
+	}
+}
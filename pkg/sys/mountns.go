@@ -0,0 +1,95 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+const nsfsMagic = 0x6e736673
+
+// NS_GET_NSTYPE from <linux/nsfs.h>
+var nsGetNSType = iocIO(0xb7, 0x3)
+
+var ErrInvalidNamespace = errors.New("not a mount namespace")
+
+// MountNamespaceOf returns the mount namespace path of process pid
+func MountNamespaceOf(pid int) string {
+	return filepath.Join(ProcRoot, strconv.Itoa(pid), "ns", "mnt")
+}
+
+func checkMountNamespace(nsPath string) error {
+	fd, err := unix.Open(nsPath, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidNamespace, nsPath, err)
+	}
+	defer unix.Close(fd)
+
+	var st unix.Statfs_t
+	if err := unix.Fstatfs(fd, &st); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidNamespace, nsPath, err)
+	}
+	if st.Type != nsfsMagic {
+		return fmt.Errorf("%w: %s is not a namespace file", ErrInvalidNamespace, nsPath)
+	}
+	nsType, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), nsGetNSType, 0)
+	if errno != 0 {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidNamespace, nsPath, errno)
+	}
+	if nsType != unix.CLONE_NEWNS {
+		return fmt.Errorf("%w: %s", ErrInvalidNamespace, nsPath)
+	}
+	return nil
+}
+
+// BindMountInNamespace bind mounts source onto target inside the mount
+// namespace at nsPath, e.g. MountNamespaceOf(pid), so that the mount is
+// visible only there. Both paths are resolved inside that namespace.
+//
+// A multithreaded process cannot setns(2) into another mount namespace,
+// which rules out doing this from the Go runtime itself; nsenter is used
+// instead. The caller needs CAP_SYS_ADMIN and CAP_SYS_CHROOT in the
+// initial user namespace, and must run in the host pid namespace to
+// reach other pods' namespaces.
+func BindMountInNamespace(nsPath, source, target string, readOnly bool) error {
+	if err := checkMountNamespace(nsPath); err != nil {
+		return err
+	}
+
+	nsenter := func(args ...string) error {
+		args = append([]string{"--mount=" + nsPath, "--", "mount"}, args...)
+		if _, stderr, err := run(context.Background(), "nsenter", args...); err != nil {
+			return fmt.Errorf("could not bind mount %s on %s in %s: %v: %s", source, target, nsPath, err, stderr)
+		}
+		return nil
+	}
+
+	if err := nsenter("--bind", source, target); err != nil {
+		return err
+	}
+	if readOnly {
+		// ro is ignored on the initial bind and needs a remount
+		return nsenter("-o", "remount,bind,ro", target)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	nvmeCtrlRegex   = regexp.MustCompile(`^nvme\d+$`)
+	nvmeSubsysRegex = regexp.MustCompile(`^nvme-subsys\d+$`)
+)
+
+// nvmeController finds the controller, e.g. nvme0, backing an NVMe
+// namespace. Multipath namespaces hang off their subsystem instead, in
+// which case the first of its controllers is used.
+func nvmeController(disk string) (string, error) {
+	p, err := sysfsBlockPath(disk)
+	if err != nil {
+		return "", err
+	}
+
+	for _, c := range strings.Split(p, "/") {
+		if nvmeCtrlRegex.MatchString(c) {
+			return c, nil
+		}
+	}
+	for _, c := range strings.Split(p, "/") {
+		if !nvmeSubsysRegex.MatchString(c) {
+			continue
+		}
+		entries, err := ioutil.ReadDir(filepath.Join(SysfsRoot, "class", "nvme-subsystem", c))
+		if err != nil {
+			return "", err
+		}
+		for _, e := range entries {
+			if nvmeCtrlRegex.MatchString(e.Name()) {
+				return e.Name(), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("could not find the nvme controller of %s", disk)
+}
+
+// IsNVMeFabrics reports whether devName is an NVMe namespace attached over
+// a fabric rather than PCIe, along with the transport (tcp, rdma, fc or
+// loop). Such namespaces can vanish on a network partition.
+func IsNVMeFabrics(devName string) (bool, string, error) {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return false, "", err
+	}
+	if !strings.HasPrefix(disk, "nvme") {
+		return false, "", nil
+	}
+
+	ctrl, err := nvmeController(disk)
+	if err != nil {
+		return false, "", err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(SysfsRoot, "class", "nvme", ctrl, "transport"))
+	if err != nil {
+		return false, "", err
+	}
+	transport := strings.TrimSpace(string(b))
+	return transport != "pcie", transport, nil
+}
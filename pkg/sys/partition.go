@@ -0,0 +1,68 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	PartTypeEFISystem  = "c12a7328-f81f-11d2-ba4b-00a0c93ec93b"
+	PartTypeBIOSBoot   = "21686148-6449-6e6f-744e-656564454649"
+	PartTypeLinuxFS    = "0fc63daf-8483-4772-8e79-3d69d8477de4"
+	PartTypeLinuxLVM   = "e6d6d379-f507-44c2-a23c-238f2a3df928"
+	PartTypeLinuxRAID  = "a19d880f-05fc-4d3b-a006-743f0f84911e"
+	PartTypeLinuxSwap  = "0657fd6d-a4ab-43c4-84e5-0933c84b4f4f"
+	PartTypeLinuxHome  = "933ac7e1-2eb4-4f13-b844-0e14e2aef915"
+	PartTypeLinuxRoot  = "4f68bce3-e8cd-4db1-96e7-fbcaf984b709"
+	PartTypeLinuxLUKS  = "ca7d7ccb-63ed-4c53-861c-1742536059cc"
+	PartTypeMSBasic    = "ebd0a0a2-b9e5-4433-87c0-68b6b72699c7"
+	PartTypeMSReserved = "e3c9e316-0b5c-4db8-817d-f92df00215ae"
+)
+
+var partitionTypeNames = map[string]string{
+	PartTypeEFISystem:  "EFI system",
+	PartTypeBIOSBoot:   "BIOS boot",
+	PartTypeLinuxFS:    "Linux filesystem",
+	PartTypeLinuxLVM:   "Linux LVM",
+	PartTypeLinuxRAID:  "Linux RAID",
+	PartTypeLinuxSwap:  "Linux swap",
+	PartTypeLinuxHome:  "Linux home",
+	PartTypeLinuxRoot:  "Linux root (x86-64)",
+	PartTypeLinuxLUKS:  "Linux LUKS",
+	PartTypeMSBasic:    "Microsoft basic data",
+	PartTypeMSReserved: "Microsoft reserved",
+}
+
+// PartitionTypeName returns a readable name for a GPT partition type GUID
+func PartitionTypeName(guid string) string {
+	if name, ok := partitionTypeNames[strings.ToLower(guid)]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%s)", guid)
+}
+
+// IsBootPartitionType reports whether guid marks an EFI or BIOS boot
+// partition, which must never be claimed
+func IsBootPartitionType(guid string) bool {
+	switch strings.ToLower(guid) {
+	case PartTypeEFISystem, PartTypeBIOSBoot:
+		return true
+	}
+	return false
+}
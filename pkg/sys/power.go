@@ -0,0 +1,113 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+type PowerState string
+
+const (
+	PowerStateActive  PowerState = "active"
+	PowerStateStandby PowerState = "standby"
+	PowerStateSleep   PowerState = "sleep"
+	PowerStateUnknown PowerState = "unknown"
+)
+
+// ReadDrivePowerState asks the drive for its power mode without waking it
+func ReadDrivePowerState(devName string) (PowerState, error) {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return PowerStateUnknown, err
+	}
+	out, stderr, err := run(context.Background(), "hdparm", "-C", getBlockFile(disk))
+	if err != nil {
+		return PowerStateUnknown, fmt.Errorf("could not read power state of %s: %v: %s", disk, err, stderr)
+	}
+
+	// e.g. " drive state is:  active/idle"
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.Contains(line, "drive state is:") {
+			continue
+		}
+		state := strings.TrimSpace(strings.SplitN(line, ":", 2)[1])
+		switch {
+		case strings.HasPrefix(state, "active"), strings.HasPrefix(state, "idle"):
+			return PowerStateActive, nil
+		case strings.HasPrefix(state, "standby"):
+			return PowerStateStandby, nil
+		case strings.HasPrefix(state, "sleeping"):
+			return PowerStateSleep, nil
+		}
+	}
+	return PowerStateUnknown, nil
+}
+
+// SetDrivePowerState spins the disk holding devName down to standby or
+// sleep, or back up to active. Drives with mounted filesystems are left
+// alone, since the next write would only wake them again.
+func SetDrivePowerState(devName string, state PowerState) error {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return err
+	}
+	mounted, err := IsDiskMounted(disk)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		return fmt.Errorf("refusing to change power state of %s: it has mounted filesystems", disk)
+	}
+
+	var flag string
+	switch state {
+	case PowerStateStandby:
+		flag = "-y"
+	case PowerStateSleep:
+		flag = "-Y"
+	case PowerStateActive:
+		return wakeDrive(disk)
+	default:
+		return fmt.Errorf("invalid power state %q", state)
+	}
+	if _, stderr, err := run(context.Background(), "hdparm", flag, getBlockFile(disk)); err != nil {
+		return fmt.Errorf("could not set %s to %s: %v: %s", disk, state, err, stderr)
+	}
+	return nil
+}
+
+// wakeDrive spins a drive up by reading from it, bypassing the page cache
+func wakeDrive(disk string) error {
+	f, err := openBlockFile(disk, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, 4096)
+	if err := unix.Fadvise(int(f.Fd()), 0, int64(len(buf)), unix.FADV_DONTNEED); err != nil {
+		return fmt.Errorf("could not drop cached pages of %s: %v", disk, err)
+	}
+	_, err = f.ReadAt(buf, 0)
+	return err
+}
@@ -0,0 +1,124 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const btrfsSuperblockOffset = 0x10000
+
+var (
+	btrfsMagic = []byte("_BHRfS_M")
+
+	ErrUnknownFS        = errors.New("no known filesystem found")
+	ErrEmptyDevice      = errors.New("device is empty")
+	ErrHibernationImage = errors.New("device holds a hibernation image")
+)
+
+// swsusp replaces the swap signature in the last 10 bytes of the first
+// page with one of these while a hibernation image is stored
+var hibernationSignatures = [][]byte{
+	[]byte("S1SUSPEND"),
+	[]byte("S2SUSPEND"),
+	[]byte("ULSUSPEND"),
+	[]byte("LINHIB0001"),
+}
+
+// the swap header fills one page of the system that created it, which
+// need not be this one
+var swapPageSizes = []int64{4096, 8192, 16384, 65536}
+
+func isBtrfs(r io.ReaderAt) bool {
+	// the magic sits at 0x40 into the superblock
+	magic := make([]byte, len(btrfsMagic))
+	if _, err := r.ReadAt(magic, btrfsSuperblockOffset+0x40); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, btrfsMagic)
+}
+
+// ProbeFSTypeReaderAt identifies the filesystem in r, which may be a
+// local device or an image fetched from elsewhere, by its superblock.
+// ErrEmptyDevice is returned when r holds no data at all, as zero sized
+// devices do, and ErrUnknownFS when no supported filesystem is found,
+// including when r is too short to hold one. Read errors are returned as
+// they are, since a device that cannot be read is not known to be blank.
+func ProbeFSTypeReaderAt(r io.ReaderAt) (FSType, error) {
+	// everything probed for lies within the first btrfs superblock
+	buf := make([]byte, btrfsSuperblockOffset+0x40+len(btrfsMagic))
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("could not read superblocks: %w", err)
+	}
+	if n == 0 {
+		return "", ErrEmptyDevice
+	}
+
+	sb := bytes.NewReader(buf[:n])
+	if _, err := readXFSSuperblock(sb); err == nil {
+		return FSTypeXFS, nil
+	}
+	if _, err := readEXT4Superblock(sb); err == nil {
+		return FSTypeEXT4, nil
+	}
+	if isBtrfs(sb) {
+		return FSTypeBtrfs, nil
+	}
+	return "", ErrUnknownFS
+}
+
+// ProbeFSType identifies the filesystem on devName by its superblock
+func ProbeFSType(devName string) (FSType, error) {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return ProbeFSTypeReaderAt(f)
+}
+
+// CheckHibernationImage fails with ErrHibernationImage if devName is a
+// swap area holding a hibernation image, which a system would resume
+// from and formatting would destroy
+func CheckHibernationImage(devName string) error {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sig := make([]byte, 10)
+	for _, pageSize := range swapPageSizes {
+		if _, err := f.ReadAt(sig, pageSize-int64(len(sig))); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		for _, s := range hibernationSignatures {
+			if bytes.HasPrefix(sig, s) {
+				return fmt.Errorf("%w: %s (%s)", ErrHibernationImage, devName, bytes.TrimRight(sig, "\x00"))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func xfsFixture(t *testing.T) []byte {
+	sb := xfsSuperblock{
+		MagicNum:   xfsSBMagic,
+		BlockSize:  4096,
+		AGBlocks:   1024,
+		AGCount:    4,
+		SectSize:   512,
+		VersionNum: xfsSBVersion5,
+	}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.BigEndian, &sb); err != nil {
+		t.Fatal(err)
+	}
+	return append(buf.Bytes(), make([]byte, 4096)...)
+}
+
+func ext4Fixture(t *testing.T) []byte {
+	sb := ext4Superblock{Magic: ext4Magic, LogBlockSize: 2}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, &sb); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, ext4SuperblockOffset+4096)
+	copy(b[ext4SuperblockOffset:], buf.Bytes())
+	return b
+}
+
+func btrfsFixture() []byte {
+	b := make([]byte, btrfsSuperblockOffset+4096)
+	copy(b[btrfsSuperblockOffset+0x40:], btrfsMagic)
+	return b
+}
+
+func TestProbeFSTypeReaderAt(t *testing.T) {
+	testCases := []struct {
+		name   string
+		image  []byte
+		fsType FSType
+		err    error
+	}{
+		{name: "xfs", image: xfsFixture(t), fsType: FSTypeXFS},
+		{name: "ext4", image: ext4Fixture(t), fsType: FSTypeEXT4},
+		{name: "btrfs", image: btrfsFixture(), fsType: FSTypeBtrfs},
+		{name: "empty", image: []byte{}, err: ErrEmptyDevice},
+		{name: "zeroed", image: make([]byte, 1024*1024), err: ErrUnknownFS},
+		{name: "single sector", image: make([]byte, 512), err: ErrUnknownFS},
+		{name: "truncated xfs superblock", image: xfsFixture(t)[:64], err: ErrUnknownFS},
+		{name: "truncated ext4 superblock", image: ext4Fixture(t)[:ext4SuperblockOffset+32], err: ErrUnknownFS},
+		{name: "truncated btrfs superblock", image: btrfsFixture()[:btrfsSuperblockOffset+0x44], err: ErrUnknownFS},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fsType, err := ProbeFSTypeReaderAt(bytes.NewReader(testCase.image))
+			if !errors.Is(err, testCase.err) {
+				t.Fatalf("expected error %v, got %v", testCase.err, err)
+			}
+			if fsType != testCase.fsType {
+				t.Errorf("expected %q, got %q", testCase.fsType, fsType)
+			}
+		})
+	}
+}
+
+// failingReaderAt fails every read past the first failAt bytes
+type failingReaderAt struct {
+	image  []byte
+	failAt int64
+	err    error
+}
+
+func (f failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > f.failAt {
+		n, _ := bytes.NewReader(f.image[:f.failAt]).ReadAt(p, off)
+		return n, f.err
+	}
+	return bytes.NewReader(f.image).ReadAt(p, off)
+}
+
+func TestProbeFSTypeReadError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		failAt int64
+	}{
+		{name: "unreadable device", failAt: 0},
+		{name: "unreadable past the first sectors", failAt: 4096},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			r := failingReaderAt{image: make([]byte, MiB), failAt: testCase.failAt, err: unix.EIO}
+			_, err := ProbeFSTypeReaderAt(r)
+			if !errors.Is(err, unix.EIO) {
+				t.Errorf("expected EIO, got %v", err)
+			}
+			if errors.Is(err, ErrUnknownFS) || errors.Is(err, ErrEmptyDevice) {
+				t.Errorf("read error reported as a blank device: %v", err)
+			}
+		})
+	}
+}
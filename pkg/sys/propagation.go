@@ -0,0 +1,71 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+	"k8s.io/utils/mount"
+)
+
+type PropagationMode string
+
+const (
+	PropagationShared  PropagationMode = "rshared"
+	PropagationSlave   PropagationMode = "rslave"
+	PropagationPrivate PropagationMode = "rprivate"
+)
+
+func (p PropagationMode) flags() (uintptr, error) {
+	switch p {
+	case PropagationShared:
+		return unix.MS_SHARED | unix.MS_REC, nil
+	case PropagationSlave:
+		return unix.MS_SLAVE | unix.MS_REC, nil
+	case PropagationPrivate:
+		return unix.MS_PRIVATE | unix.MS_REC, nil
+	}
+	return 0, fmt.Errorf("invalid mount propagation mode %q", p)
+}
+
+// SetMountPropagation changes the propagation of the mount at path, and
+// of every mount below it. If path is not a mount point yet, it is first
+// bind mounted onto itself, since propagation is a property of mounts.
+func SetMountPropagation(path string, mode PropagationMode) error {
+	flags, err := mode.flags()
+	if err != nil {
+		return err
+	}
+
+	notMount, err := mount.IsNotMountPoint(mount.New(""), path)
+	if err != nil {
+		return err
+	}
+	if notMount {
+		glog.V(5).Infof("bind mounting %s onto itself to set %s propagation", path, mode)
+		if err := unix.Mount(path, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("could not bind mount %s onto itself: %v", path, err)
+		}
+	}
+
+	if err := unix.Mount("", path, "", flags, ""); err != nil {
+		return fmt.Errorf("could not make %s %s: %v", path, mode, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var ErrQuotaNotEnabled = errors.New("quota is not enabled")
+
+// QuotaMode is the kind of quota a filesystem is mounted to enforce
+type QuotaMode string
+
+const (
+	QuotaNone    QuotaMode = ""
+	QuotaUser    QuotaMode = "user"
+	QuotaGroup   QuotaMode = "group"
+	QuotaProject QuotaMode = "project"
+)
+
+// quotactl commands and types from <linux/quota.h>
+const (
+	qGetInfo     = 0x800005
+	qSubCmdShift = 8
+
+	usrQuota = 0
+	grpQuota = 1
+	prjQuota = 2
+)
+
+type ifDqinfo struct {
+	bgrace uint64
+	igrace uint64
+	flags  uint32
+	valid  uint32
+}
+
+// MountOption returns the mount option enabling the quota on XFS and
+// ext4, which both use the same names
+func (q QuotaMode) MountOption() (string, error) {
+	switch q {
+	case QuotaUser:
+		return "usrquota", nil
+	case QuotaGroup:
+		return "grpquota", nil
+	case QuotaProject:
+		return "prjquota", nil
+	}
+	return "", fmt.Errorf("invalid quota mode %q", q)
+}
+
+func (q QuotaMode) quotaType() int {
+	switch q {
+	case QuotaGroup:
+		return grpQuota
+	case QuotaProject:
+		return prjQuota
+	}
+	return usrQuota
+}
+
+func supportsQuota(fsType FSType) bool {
+	return fsType == FSTypeXFS || fsType == FSTypeEXT4
+}
+
+// CheckQuotaActive fails with ErrQuotaNotEnabled unless the kernel is
+// enforcing quota q on the mounted filesystem on devName
+func CheckQuotaActive(devName string, q QuotaMode) error {
+	special, err := unix.BytePtrFromString(getBlockFile(devName))
+	if err != nil {
+		return err
+	}
+	var info ifDqinfo
+	cmd := qGetInfo<<qSubCmdShift | q.quotaType()
+	_, _, errno := unix.Syscall6(unix.SYS_QUOTACTL, uintptr(cmd), uintptr(unsafe.Pointer(special)), 0, uintptr(unsafe.Pointer(&info)), 0, 0)
+	switch errno {
+	case 0:
+		return nil
+	case unix.ESRCH, unix.ENOSYS, unix.ENOTSUP:
+		return fmt.Errorf("%w: %s quota on %s: %v", ErrQuotaNotEnabled, q, devName, errno)
+	}
+	return fmt.Errorf("could not query %s quota on %s: %v", q, devName, errno)
+}
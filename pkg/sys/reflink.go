@@ -0,0 +1,110 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var ficlone = iocIOW(btrfsIoctlMagic, 9, unsafe.Sizeof(int32(0)))
+
+var (
+	reflinkCacheLock sync.Mutex
+	reflinkCache     = map[uint64]bool{}
+)
+
+// SupportsReflink reports whether files on the filesystem mounted at
+// mountpoint can be cloned with FICLONE. The answer is worked out by
+// cloning a scratch file, and is cached per filesystem.
+func SupportsReflink(mountpoint string) (bool, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(mountpoint, &st); err != nil {
+		return false, err
+	}
+
+	reflinkCacheLock.Lock()
+	defer reflinkCacheLock.Unlock()
+
+	if ok, found := reflinkCache[st.Dev]; found {
+		return ok, nil
+	}
+
+	ok, err := tryReflink(mountpoint)
+	if err != nil {
+		return false, err
+	}
+	reflinkCache[st.Dev] = ok
+	return ok, nil
+}
+
+func tryReflink(dir string) (bool, error) {
+	src, err := ioutil.TempFile(dir, ".reflink-probe-")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(src.Name())
+	defer src.Close()
+
+	if _, err := src.Write(make([]byte, 4096)); err != nil {
+		return false, err
+	}
+	if err := src.Sync(); err != nil {
+		return false, err
+	}
+
+	dst, err := ioutil.TempFile(dir, ".reflink-probe-")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+
+	switch err := Reflink(dst, src); {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrReflinkUnsupported):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// ErrReflinkUnsupported matches the error returned when files cannot be
+// cloned, whatever the filesystem
+var ErrReflinkUnsupported = &ErrUnsupportedOperation{Operation: "reflink"}
+
+// Reflink makes dst share the extents of src with FICLONE, so that it
+// reads the same without copying any data. An ErrUnsupportedOperation is
+// returned if the filesystem cannot clone, or the files are on different
+// filesystems.
+func Reflink(dst, src *os.File) error {
+	switch err := ioctlValue(dst.Fd(), ficlone, src.Fd()); err {
+	case nil:
+		return nil
+	case unix.EOPNOTSUPP, unix.EXDEV, unix.EINVAL, unix.ENOTTY:
+		return fmt.Errorf("could not clone %s to %s: %w", src.Name(), dst.Name(), unsupported(fsTypeOf(dst), "reflink"))
+	default:
+		return fmt.Errorf("could not clone %s to %s: %v", src.Name(), dst.Name(), err)
+	}
+}
@@ -0,0 +1,79 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+	"k8s.io/utils/mount"
+)
+
+// RemountWithOptions remounts mountpoint in place so that options are in
+// effect, keeping its other current options. Nothing is done if they are
+// already in effect, so it is safe to call on every reconcile.
+//
+// Volumes are bind mounts of directories on a shared drive, and a plain
+// remount changes the superblock under every one of them. On bind mounts
+// only per-mount flags are changed, with MS_BIND, and filesystem options
+// are refused.
+func RemountWithOptions(mountpoint string, options []string) error {
+	info, err := findMount(mountpoint)
+	if err != nil {
+		return err
+	}
+
+	current := mountInfoFlags(info)
+	missing := false
+	for _, o := range ParseMountFlags(options).Options() {
+		if !current.Has(o) {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return nil
+	}
+
+	// per-mount flags not given are reset by a remount, so the current
+	// ones are carried over, while filesystem options are left as they are
+	// unless given
+	merged := ParseMountFlags(info.MountOptions)
+	merged.data = nil
+	merged.Parse(options)
+	flags := unix.MS_REMOUNT | merged.Flags()
+	data := merged.Data()
+	if isBindMount(info) {
+		if data != "" {
+			return fmt.Errorf("could not remount %s: filesystem options %s would apply to the whole filesystem, not just the bind mount", mountpoint, data)
+		}
+		flags |= unix.MS_BIND
+	}
+
+	glog.V(5).Infof("remounting %s with options %v", mountpoint, options)
+	if err := unix.Mount("", mountpoint, "", flags, data); err != nil {
+		return fmt.Errorf("could not remount %s: %v", mountpoint, err)
+	}
+	return nil
+}
+
+// isBindMount reports whether info mounts a directory below the root of
+// its filesystem, as volume bind mounts do
+func isBindMount(info *mount.MountInfo) bool {
+	return info.Root != "/"
+}
@@ -0,0 +1,68 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ReplacementSizeTolerance is how much larger, as a fraction of the
+// failed drive's size, a replacement may be. Drives sold with the same
+// nominal capacity differ slightly between vendors, but a smaller one
+// could not hold what was provisioned on the old drive.
+const ReplacementSizeTolerance = 0.02
+
+var ErrNoReplacement = errors.New("no replacement drive within size tolerance")
+
+// MatchReplacement picks the candidate most likely to replace the failed
+// drive old. Candidates must be at least as large, and larger by no more
+// than ReplacementSizeTolerance; among those, sitting in the same slot
+// counts most, then having the same class under rules, then the same
+// transport. The result is meant to be confirmed by an operator, never
+// adopted automatically.
+func MatchReplacement(old DriveAttributes, candidates []DriveAttributes, rules []ClassRule) (*DriveAttributes, error) {
+	tolerance := uint64(float64(old.Size) * ReplacementSizeTolerance)
+	oldClass := DriveClass(old, rules)
+
+	var best *DriveAttributes
+	bestScore := -1
+	for i := range candidates {
+		c := &candidates[i]
+		if c.Size < old.Size || !CapacityApproxEqual(c.Size, old.Size, tolerance) {
+			continue
+		}
+		score := 0
+		if old.ByPath != "" && c.ByPath == old.ByPath {
+			score += 4
+		}
+		if oldClass != "" && DriveClass(*c, rules) == oldClass {
+			score += 2
+		}
+		if c.Transport == old.Transport {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("%w: %d to %d bytes", ErrNoReplacement, old.Size, old.Size+tolerance)
+	}
+	return best, nil
+}
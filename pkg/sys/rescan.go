@@ -0,0 +1,272 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"unsafe"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	blkRRPart = iocIO(0x12, 95)
+	blkPG     = iocIO(0x12, 105)
+)
+
+// operations and argument structs from <linux/blkpg.h>
+const (
+	blkpgAddPartition = 1
+	blkpgDelPartition = 2
+)
+
+type blkpgIoctlArg struct {
+	op      int32
+	flags   int32
+	datalen int32
+	data    unsafe.Pointer
+}
+
+type blkpgPartition struct {
+	start   int64
+	length  int64
+	pno     int32
+	devname [64]byte
+	volname [64]byte
+}
+
+// partitionExtent is a partition's number and its extent in bytes
+type partitionExtent struct {
+	number int
+	start  int64
+	length int64
+	// extended MBR partitions and the logical ones inside them are left
+	// to the kernel, which sizes them differently
+	extended bool
+}
+
+var gptSignature = []byte("EFI PART")
+
+const (
+	mbrTypeGPTProtective = 0xee
+	mbrTypeExtended      = 0x05
+	mbrTypeExtendedLBA   = 0x0f
+)
+
+// readPartitionTable reads the GPT, or failing that the primary MBR
+// partitions, of the disk behind r. Logical partitions inside an
+// extended MBR partition are not read.
+func readPartitionTable(r io.ReaderAt, sectorSize int64) ([]partitionExtent, error) {
+	mbr := make([]byte, 512)
+	if _, err := r.ReadAt(mbr, 0); err != nil {
+		return nil, err
+	}
+	if mbr[510] != 0x55 || mbr[511] != 0xaa {
+		return nil, nil
+	}
+
+	parts := []partitionExtent{}
+	for i := 0; i < 4; i++ {
+		e := mbr[446+16*i : 446+16*(i+1)]
+		switch e[4] {
+		case 0:
+			continue
+		case mbrTypeGPTProtective:
+			return readGPT(r, sectorSize)
+		case mbrTypeExtended, mbrTypeExtendedLBA:
+			parts = append(parts, partitionExtent{number: i + 1, extended: true})
+			continue
+		}
+		parts = append(parts, partitionExtent{
+			number: i + 1,
+			start:  int64(binary.LittleEndian.Uint32(e[8:12])) * sectorSize,
+			length: int64(binary.LittleEndian.Uint32(e[12:16])) * sectorSize,
+		})
+	}
+	return parts, nil
+}
+
+func readGPT(r io.ReaderAt, sectorSize int64) ([]partitionExtent, error) {
+	header := make([]byte, 92)
+	if _, err := r.ReadAt(header, sectorSize); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:8], gptSignature) {
+		return nil, fmt.Errorf("protective MBR without a GPT header")
+	}
+	entriesLBA := int64(binary.LittleEndian.Uint64(header[72:80]))
+	count := int(binary.LittleEndian.Uint32(header[80:84]))
+	entrySize := int64(binary.LittleEndian.Uint32(header[84:88]))
+	if entrySize < 128 || count > 1024 {
+		return nil, fmt.Errorf("corrupt GPT header")
+	}
+
+	parts := []partitionExtent{}
+	entry := make([]byte, entrySize)
+	for i := 0; i < count; i++ {
+		if _, err := r.ReadAt(entry, entriesLBA*sectorSize+int64(i)*entrySize); err != nil {
+			return nil, err
+		}
+		if isZero(entry[:16]) {
+			continue
+		}
+		first := int64(binary.LittleEndian.Uint64(entry[32:40]))
+		last := int64(binary.LittleEndian.Uint64(entry[40:48]))
+		parts = append(parts, partitionExtent{
+			number: i + 1,
+			start:  first * sectorSize,
+			length: (last - first + 1) * sectorSize,
+		})
+	}
+	return parts, nil
+}
+
+// kernelPartitions returns the partitions the kernel currently knows of
+// disk, keyed by partition number, along with their device names
+func kernelPartitions(disk string) (map[int]partitionExtent, map[int]string, error) {
+	dir := filepath.Join(sysClassBlock(), disk)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parts := map[int]partitionExtent{}
+	names := map[int]string{}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		number, err := readSysfsUint(filepath.Join(p, "partition"))
+		if err != nil {
+			continue
+		}
+		// sysfs always counts in 512 byte sectors
+		start, err := readSysfsUint(filepath.Join(p, "start"))
+		if err != nil {
+			return nil, nil, err
+		}
+		size, err := readSysfsUint(filepath.Join(p, "size"))
+		if err != nil {
+			return nil, nil, err
+		}
+		parts[int(number)] = partitionExtent{
+			number: int(number),
+			start:  int64(start) * 512,
+			length: int64(size) * 512,
+		}
+		names[int(number)] = e.Name()
+	}
+	return parts, names, nil
+}
+
+func blkpg(f *os.File, op int32, p partitionExtent) error {
+	part := blkpgPartition{
+		start:  p.start,
+		length: p.length,
+		pno:    int32(p.number),
+	}
+	arg := blkpgIoctlArg{
+		op:      op,
+		datalen: int32(unsafe.Sizeof(part)),
+		data:    unsafe.Pointer(&part),
+	}
+	err := ioctl(f.Fd(), blkPG, unsafe.Pointer(&arg))
+	// the kernel follows arg.data, which the GC does not see being used
+	runtime.KeepAlive(&part)
+	return err
+}
+
+// RereadPartitionTable makes the kernel pick up the partition table of
+// devName after it was written. The whole disk is rescanned with
+// BLKRRPART, which the kernel refuses while any partition is in use; in
+// that case the partitions that changed are updated one by one with
+// BLKPG, which fails with ErrDeviceInUse only if a changed partition is
+// itself in use.
+func RereadPartitionTable(devName string) error {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return err
+	}
+	f, err := openBlockFile(disk, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = ioctlValue(f.Fd(), blkRRPart, 0)
+	if err == nil {
+		return nil
+	}
+	if err != unix.EBUSY {
+		return fmt.Errorf("could not reread partition table of %s: %v", disk, err)
+	}
+	glog.V(5).Infof("%s is busy, updating changed partitions individually", disk)
+
+	logical, _, _, err := SectorType(disk)
+	if err != nil {
+		return err
+	}
+	table, err := readPartitionTable(f, int64(logical))
+	if err != nil {
+		return fmt.Errorf("could not read partition table of %s: %v", disk, err)
+	}
+	current, names, err := kernelPartitions(disk)
+	if err != nil {
+		return err
+	}
+
+	wanted := map[int]partitionExtent{}
+	extended := false
+	for _, p := range table {
+		wanted[p.number] = p
+		extended = extended || p.extended
+	}
+
+	// drop partitions that are gone or moved before adding any, so that
+	// new ones never overlap stale ones
+	for number, p := range current {
+		if w, ok := wanted[number]; ok && (w == p || w.extended) {
+			continue
+		}
+		if extended && number > 4 {
+			continue
+		}
+		if err := blkpg(f, blkpgDelPartition, p); err != nil {
+			if errors.Is(err, unix.EBUSY) {
+				return fmt.Errorf("%w: partition %s changed but is busy", ErrDeviceInUse, names[number])
+			}
+			return fmt.Errorf("could not remove partition %s: %v", names[number], err)
+		}
+		delete(current, number)
+	}
+	for number, p := range wanted {
+		if _, ok := current[number]; ok || p.extended {
+			continue
+		}
+		if err := blkpg(f, blkpgAddPartition, p); err != nil {
+			return fmt.Errorf("could not add partition %d of %s: %v", number, disk, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const trashDir = ".trash"
+
+type RetentionMode string
+
+const (
+	// RetentionImmediate removes the volume right away
+	RetentionImmediate RetentionMode = "immediate"
+	// RetentionTrash moves the volume into the drive's trash, where it is
+	// kept until an operator removes it
+	RetentionTrash RetentionMode = "trash"
+	// RetentionDelayed moves the volume into the drive's trash, from where
+	// the sweeper removes it once the grace period has passed
+	RetentionDelayed RetentionMode = "delayed"
+)
+
+type RetentionPolicy struct {
+	Mode  RetentionMode
+	Grace time.Duration
+}
+
+// trashed volumes are named <volume>@<unix nano deleted>@<unix expiry>,
+// where an expiry of 0 never expires. The deletion time keeps a volume
+// trashed more than once from colliding with its earlier copies.
+func trashName(volume string, deleted, expiry time.Time) string {
+	var ts int64
+	if !expiry.IsZero() {
+		ts = expiry.Unix()
+	}
+	return fmt.Sprintf("%s@%d@%d", volume, deleted.UnixNano(), ts)
+}
+
+func trashExpiry(name string) (time.Time, bool) {
+	i := strings.LastIndex(name, "@")
+	if i < 0 {
+		return time.Time{}, false
+	}
+	ts, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil || ts == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(ts, 0), true
+}
+
+// RetireVolume retires the volume directory at path, which lives on the
+// filesystem mounted at mountpoint, according to policy. Volumes removed
+// right away are reclaimed with deletion. Trashed volumes stay on the
+// same drive, so retiring is just a rename and recovering one is a
+// rename back.
+func RetireVolume(path, mountpoint string, policy RetentionPolicy, deletion DeletionPolicy) error {
+	now := time.Now()
+	var expiry time.Time
+	switch policy.Mode {
+	case RetentionImmediate:
+		return ReclaimVolume(path, mountpoint, deletion)
+	case RetentionTrash:
+	case RetentionDelayed:
+		if policy.Grace <= 0 {
+			return fmt.Errorf("delayed deletion of %s needs a positive grace period", path)
+		}
+		expiry = now.Add(policy.Grace)
+	default:
+		return fmt.Errorf("invalid retention mode %q", policy.Mode)
+	}
+
+	trash := filepath.Join(filepath.Dir(path), trashDir)
+	if err := os.MkdirAll(trash, 0700); err != nil {
+		return err
+	}
+	target := filepath.Join(trash, trashName(filepath.Base(path), now, expiry))
+	if err := os.Rename(path, target); err != nil {
+		return fmt.Errorf("could not move %s to trash: %v", path, err)
+	}
+	glog.V(5).Infof("moved volume %s to %s", path, target)
+	return nil
+}
+
+// SweepTrash removes the trashed volumes under basePath whose grace
+// period has passed
+func SweepTrash(basePath string) error {
+	trash := filepath.Join(basePath, trashDir)
+	entries, err := ioutil.ReadDir(trash)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		expiry, ok := trashExpiry(e.Name())
+		if !ok || now.Before(expiry) {
+			continue
+		}
+		p := filepath.Join(trash, e.Name())
+		if err := os.RemoveAll(p); err != nil {
+			return fmt.Errorf("could not reclaim %s: %v", p, err)
+		}
+		glog.V(5).Infof("reclaimed trashed volume %s", p)
+	}
+	return nil
+}
+
+// RunTrashSweeper sweeps the trash of every base path each interval
+// until ctx is cancelled
+func RunTrashSweeper(ctx context.Context, basePaths []string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, p := range basePaths {
+			if err := SweepTrash(p); err != nil {
+				glog.Errorf("could not sweep trash of %s: %v", p, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+// CommandRunner runs the external tools this package depends on, feeding
+// stdin, which may be nil, to their standard input. Errors for commands
+// that ran but failed should have an ExitCode() int method, as
+// *exec.ExitError does. LookPath finds a command the way Run would,
+// without running it.
+type CommandRunner interface {
+	Run(ctx context.Context, stdin io.Reader, name string, args ...string) (stdout, stderr []byte, err error)
+	LookPath(name string) (string, error)
+}
+
+// CommandRunnerFunc adapts a function to a CommandRunner. Commands are
+// looked up in $PATH.
+type CommandRunnerFunc func(ctx context.Context, stdin io.Reader, name string, args ...string) ([]byte, []byte, error)
+
+func (f CommandRunnerFunc) Run(ctx context.Context, stdin io.Reader, name string, args ...string) ([]byte, []byte, error) {
+	return f(ctx, stdin, name, args...)
+}
+
+func (f CommandRunnerFunc) LookPath(name string) (string, error) {
+	return exec.LookPath(name)
+}
+
+type execRunner struct{}
+
+// Run starts the command in a process group of its own, so that when ctx
+// is done the whole group, along with any helpers the tool spawned, can
+// be killed
+func (execRunner) Run(ctx context.Context, stdin io.Reader, name string, args ...string) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &unix.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return stdout.Bytes(), stderr.Bytes(), err
+	case <-ctx.Done():
+		if err := unix.Kill(-cmd.Process.Pid, unix.SIGKILL); err != nil {
+			glog.Errorf("could not kill %s: %v", name, err)
+		}
+		<-done
+		return stdout.Bytes(), stderr.Bytes(), ctx.Err()
+	}
+}
+
+func (execRunner) LookPath(name string) (string, error) {
+	return exec.LookPath(name)
+}
+
+var runner CommandRunner = execRunner{}
+
+// SetCommandRunner replaces the runner used for every external command,
+// e.g. to wrap commands in nice or to fake them in tests. A nil runner
+// restores the default, which uses os/exec.
+func SetCommandRunner(r CommandRunner) {
+	if r == nil {
+		r = execRunner{}
+	}
+	runner = r
+}
+
+func run(ctx context.Context, name string, args ...string) ([]byte, []byte, error) {
+	return runWithStdin(ctx, nil, name, args...)
+}
+
+func runWithStdin(ctx context.Context, stdin io.Reader, name string, args ...string) ([]byte, []byte, error) {
+	glog.V(5).Infof("running %s %v", name, args)
+	return runner.Run(ctx, stdin, name, args...)
+}
+
+func lookPath(name string) (string, error) {
+	return runner.LookPath(name)
+}
+
+// exitCode returns the exit status of a command that ran but failed
+func exitCode(err error) (int, bool) {
+	var e interface{ ExitCode() int }
+	if errors.As(err, &e) {
+		return e.ExitCode(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,146 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func withFakeRunner(t *testing.T) *FakeCommandRunner {
+	fake := NewFakeCommandRunner()
+	SetCommandRunner(fake)
+	t.Cleanup(func() { SetCommandRunner(nil) })
+	return fake
+}
+
+func TestCryptsetupKeyOverStdin(t *testing.T) {
+	fake := withFakeRunner(t)
+	fake.Outputs["cryptsetup"] = FakeOutput{}
+
+	key := []byte("secret")
+	if _, err := OpenLUKS("/dev/sdz", "vol", key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	commands := fake.Commands()
+	if len(commands) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(commands))
+	}
+	if string(commands[0].Stdin) != string(key) {
+		t.Errorf("expected key on stdin, got %q", commands[0].Stdin)
+	}
+	for _, arg := range commands[0].Args {
+		if arg == string(key) {
+			t.Errorf("key passed as an argument: %v", commands[0].Args)
+		}
+	}
+}
+
+func TestCheckFilesystemCorrected(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fsType  FSType
+		output  FakeOutput
+		command []string
+		fail    bool
+	}{
+		{
+			name:    "ext4 clean",
+			fsType:  FSTypeEXT4,
+			command: []string{"e2fsck", "-p", "/dev/sdz"},
+		},
+		{
+			name:    "ext4 errors corrected",
+			fsType:  FSTypeEXT4,
+			output:  FakeOutput{Err: &FakeExitError{Code: 1}},
+			command: []string{"e2fsck", "-p", "/dev/sdz"},
+		},
+		{
+			name:    "ext4 errors left uncorrected",
+			fsType:  FSTypeEXT4,
+			output:  FakeOutput{Err: &FakeExitError{Code: 4}},
+			command: []string{"e2fsck", "-p", "/dev/sdz"},
+			fail:    true,
+		},
+		{
+			name:    "xfs needing repair",
+			fsType:  FSTypeXFS,
+			output:  FakeOutput{Err: &FakeExitError{Code: 1}},
+			command: []string{"xfs_repair", "/dev/sdz"},
+			fail:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fake := withFakeRunner(t)
+			fake.Outputs[testCase.command[0]] = testCase.output
+
+			err := CheckFilesystem("/dev/sdz", testCase.fsType, true, 0)
+			if fail := err != nil; fail != testCase.fail {
+				t.Errorf("expected failure %v, got %v", testCase.fail, err)
+			}
+			commands := fake.Commands()
+			if len(commands) != 1 {
+				t.Fatalf("expected 1 command, got %d", len(commands))
+			}
+			got := append([]string{commands[0].Name}, commands[0].Args...)
+			if !reflect.DeepEqual(got, testCase.command) {
+				t.Errorf("expected %v, got %v", testCase.command, got)
+			}
+		})
+	}
+}
+
+func TestScrubXFS(t *testing.T) {
+	fake := withFakeRunner(t)
+	fake.Outputs["ionice"] = FakeOutput{
+		Stderr: []byte("/mnt/drive: 2 errors and 1 warnings found.  Unmount and run xfs_repair.\n"),
+		Err:    &FakeExitError{Code: xfsScrubUncorrected},
+	}
+
+	result, err := Scrub(context.Background(), "/mnt/drive", FSTypeXFS, ScrubOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ErrorsFound != 2 || !result.Uncorrected {
+		t.Errorf("expected 2 uncorrected errors, got %+v", result)
+	}
+
+	fake.Outputs["ionice"] = FakeOutput{Err: &FakeExitError{Code: xfsScrubOperational}}
+	if _, err := Scrub(context.Background(), "/mnt/drive", FSTypeXFS, ScrubOptions{}); err == nil {
+		t.Errorf("expected operational error to fail the scrub")
+	}
+}
+
+func TestLookPathThroughRunner(t *testing.T) {
+	fake := withFakeRunner(t)
+	fake.Outputs["mkfs.xfs"] = FakeOutput{}
+	fake.Outputs["dmsetup status pool"] = FakeOutput{}
+
+	for _, name := range []string{"mkfs.xfs", "dmsetup"} {
+		if _, err := lookPath(name); err != nil {
+			t.Errorf("expected %s to be found, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"mkfs.ext4", "dm"} {
+		if _, err := lookPath(name); err == nil {
+			t.Errorf("expected %s not to be found", name)
+		}
+	}
+}
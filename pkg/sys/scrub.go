@@ -0,0 +1,183 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+type ScrubOptions struct {
+	// IOPriority defaults to BackgroundIOPriority
+	IOPriority IOPriority
+}
+
+type ScrubResult struct {
+	// BytesChecked is not known for XFS and left at 0
+	BytesChecked uint64
+	ErrorsFound  uint64
+	// ErrorsCorrected is not known for XFS, whose scrubber does not count
+	// repairs, and left at 0
+	ErrorsCorrected uint64
+	// Uncorrected is set when errors were found that the scrub could not
+	// correct, which for XFS is the only reliable outcome
+	Uncorrected bool
+	// BadOffsets lists the unreadable sectors found by a read pass
+	BadOffsets []int64
+}
+
+// Scrub verifies the data of a filesystem. btrfs and XFS are scrubbed
+// online by their own tools, given the mount point in mountOrDev; every
+// other filesystem gets a pass reading each sector of the device, given
+// either the device or the mount point of a filesystem on it. Scrubbing
+// stops when ctx is cancelled.
+func Scrub(ctx context.Context, mountOrDev string, fsType FSType, opts ScrubOptions) (*ScrubResult, error) {
+	switch fsType {
+	case FSTypeBtrfs:
+		return scrubBtrfs(ctx, mountOrDev, opts.IOPriority)
+	case FSTypeXFS:
+		return scrubXFS(ctx, mountOrDev, opts.IOPriority)
+	}
+
+	devName := mountOrDev
+	if info, err := findMount(mountOrDev); err == nil {
+		devName = info.Source
+	}
+	var result *ScrubResult
+	err := withIOPriority(opts.IOPriority, func() error {
+		var err error
+		result, err = readAllSectors(ctx, devName)
+		return err
+	})
+	return result, err
+}
+
+func scrubBtrfs(ctx context.Context, mountpoint string, prio IOPriority) (*ScrubResult, error) {
+	out, stderr, err := runWithIOPriority(ctx, prio, "btrfs", "scrub", "start", "-B", "-R", mountpoint)
+	// btrfs scrub exits with 3 when it found uncorrectable errors
+	if code, ok := exitCode(err); err != nil && !(ok && code == 3) {
+		return nil, fmt.Errorf("btrfs scrub of %s failed: %v: %s", mountpoint, err, stderr)
+	}
+
+	// -R prints raw counters, one "name: value" per line
+	counters := map[string]uint64{}
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 64); err == nil {
+			counters[parts[0]] = v
+		}
+	}
+	return &ScrubResult{
+		BytesChecked: counters["data_bytes_scrubbed"] + counters["tree_bytes_scrubbed"],
+		ErrorsFound: counters["read_errors"] + counters["csum_errors"] +
+			counters["verify_errors"] + counters["super_errors"],
+		ErrorsCorrected: counters["corrected_errors"],
+		Uncorrected:     counters["uncorrectable_errors"] > 0,
+	}, nil
+}
+
+// xfs_scrub exit status bits, see xfs_scrub(8)
+const (
+	xfsScrubUncorrected = 0x1
+	xfsScrubOperational = 0x2
+)
+
+// xfs_scrub ends with a summary such as "/mnt: 2 errors and 1 warnings
+// found.  Unmount and run xfs_repair."
+var xfsScrubErrors = regexp.MustCompile(`(\d+) errors? (?:and \d+ warnings? )?found`)
+
+// parseXFSScrubErrors returns the error count from the summary xfs_scrub
+// printed, or 0 when it printed none
+func parseXFSScrubErrors(output []byte) uint64 {
+	m := xfsScrubErrors.FindSubmatch(output)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.ParseUint(string(m[1]), 10, 64)
+	return n
+}
+
+func scrubXFS(ctx context.Context, mountpoint string, prio IOPriority) (*ScrubResult, error) {
+	stdout, stderr, err := runWithIOPriority(ctx, prio, "xfs_scrub", mountpoint)
+	result := &ScrubResult{}
+	if err != nil {
+		code, ok := exitCode(err)
+		if !ok || code&xfsScrubOperational != 0 {
+			return nil, fmt.Errorf("xfs_scrub of %s failed: %v: %s", mountpoint, err, stderr)
+		}
+		result.Uncorrected = code&xfsScrubUncorrected != 0
+	}
+	result.ErrorsFound = parseXFSScrubErrors(append(append([]byte{}, stdout...), stderr...))
+	return result, nil
+}
+
+func readAllSectors(ctx context.Context, devName string) (*ScrubResult, error) {
+	logical, _, _, err := SectorType(devName)
+	if err != nil {
+		return nil, err
+	}
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// the page cache would hide errors for anything read recently
+	if err := unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED); err != nil {
+		glog.V(5).Infof("could not drop cached pages of %s: %v", devName, err)
+	}
+
+	result := &ScrubResult{}
+	buf := make([]byte, MiB)
+	sector := buf[:logical]
+	for offset := int64(0); ; offset += int64(len(buf)) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := f.ReadAt(buf, offset)
+		result.BytesChecked += uint64(n)
+		if err == io.EOF {
+			return result, nil
+		}
+		if err == nil {
+			continue
+		}
+
+		for s := offset + int64(n); s < offset+int64(len(buf)); s += int64(logical) {
+			if _, err := f.ReadAt(sector, s); err != nil {
+				if err == io.EOF {
+					return result, nil
+				}
+				result.ErrorsFound++
+				result.Uncorrected = true
+				result.BadOffsets = append(result.BadOffsets, s)
+				continue
+			}
+			result.BytesChecked += logical
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+const selinuxXattr = "security.selinux"
+
+// IsSELinuxEnabled reports whether selinuxfs is mounted on this node
+func IsSELinuxEnabled() bool {
+	_, err := os.Stat(filepath.Join(SysfsRoot, "fs", "selinux", "enforce"))
+	return err == nil
+}
+
+// SELinuxMountOption returns the mount option that labels an entire
+// filesystem with the given context at mount time. For XFS and ext4 this
+// is preferred over ApplySELinuxContext, since it avoids relabeling every
+// file in the volume. The context is quoted, since MCS category lists
+// contain commas; mount options have no escaping, so contexts containing
+// a double quote are rejected.
+func SELinuxMountOption(context string) (string, error) {
+	if context == "" || strings.Contains(context, `"`) {
+		return "", fmt.Errorf("invalid selinux context %q", context)
+	}
+	return `context="` + context + `"`, nil
+}
+
+// ApplySELinuxContext sets the SELinux context of path, and of everything
+// beneath it if recursive is set. It is a no-op when SELinux is disabled.
+func ApplySELinuxContext(path string, context string, recursive bool) error {
+	if !IsSELinuxEnabled() {
+		glog.V(5).Infof("selinux disabled, skipping relabel of %s", path)
+		return nil
+	}
+	if context == "" {
+		return fmt.Errorf("empty selinux context for %s", path)
+	}
+
+	label := func(p string) error {
+		if err := unix.Lsetxattr(p, selinuxXattr, []byte(context), 0); err != nil {
+			return fmt.Errorf("could not set selinux context on %s: %v", p, err)
+		}
+		return nil
+	}
+
+	if !recursive {
+		return label(path)
+	}
+	return filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return label(p)
+	})
+}
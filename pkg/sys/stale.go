@@ -0,0 +1,84 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+// the kernel appends this to paths in mountinfo once they are unlinked
+const deletedSuffix = "//deleted"
+
+// FindStaleBindMounts returns the bind mounts below managedRoot whose
+// mount point or backing directory has been removed, as happens when a
+// pod or kubelet crashes before unmounting. Bind mounts are recognised by
+// a mountinfo root other than "/".
+func FindStaleBindMounts(managedRoot string) ([]string, error) {
+	infos, err := readMountInfo()
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Clean(managedRoot)
+
+	stale := []string{}
+	for _, info := range infos {
+		target := info.MountPoint
+		if target != root && !strings.HasPrefix(target, root+"/") {
+			continue
+		}
+		if info.Root == "/" {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(info.Root, deletedSuffix):
+		case strings.HasSuffix(target, deletedSuffix):
+			target = strings.TrimSuffix(target, deletedSuffix)
+		default:
+			if _, err := os.Stat(target); !os.IsNotExist(err) {
+				continue
+			}
+		}
+		stale = append(stale, target)
+	}
+	return stale, nil
+}
+
+// CleanupStaleMounts lazily unmounts every stale bind mount below
+// managedRoot and returns the ones it unmounted. A lazy unmount detaches
+// the mount right away even if something still holds it open.
+func CleanupStaleMounts(managedRoot string) ([]string, error) {
+	stale, err := FindStaleBindMounts(managedRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	cleaned := []string{}
+	for _, target := range stale {
+		if err := unix.Unmount(target, unix.MNT_DETACH); err != nil && err != unix.EINVAL {
+			return cleaned, fmt.Errorf("could not unmount stale mount %s: %v", target, err)
+		}
+		glog.V(5).Infof("unmounted stale bind mount %s", target)
+		cleaned = append(cleaned, target)
+	}
+	return cleaned, nil
+}
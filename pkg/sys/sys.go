@@ -0,0 +1,26 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+// Roots of the kernel filesystems used by this package. They can be
+// pointed elsewhere when the host's paths are mounted under a prefix,
+// e.g. /host/sys, or at fixture trees.
+var (
+	SysfsRoot = "/sys"
+	ProcRoot  = "/proc"
+	DevRoot   = "/dev"
+)
@@ -0,0 +1,234 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func sysClassBlock() string {
+	return filepath.Join(SysfsRoot, "class", "block")
+}
+
+var ErrSysfsUnavailable = errors.New("sysfs is not mounted")
+
+func checkSysfs() error {
+	if _, err := os.Stat(sysClassBlock()); err != nil {
+		return fmt.Errorf("%w: %v", ErrSysfsUnavailable, err)
+	}
+	return nil
+}
+
+// blockName returns the kernel name of devName, as used in sysfs, by
+// following by-id and mapper links to the node they point at
+func blockName(devName string) string {
+	if p, err := filepath.EvalSymlinks(getBlockFile(devName)); err == nil {
+		return filepath.Base(p)
+	}
+	return filepath.Base(strings.TrimPrefix(devName, "/dev/"))
+}
+
+func sysfsBlockPath(devName string) (string, error) {
+	p, err := filepath.EvalSymlinks(filepath.Join(sysClassBlock(), blockName(devName)))
+	if err != nil {
+		if sysErr := checkSysfs(); sysErr != nil {
+			return "", sysErr
+		}
+		return "", fmt.Errorf("could not find %s in sysfs: %v", devName, err)
+	}
+	return p, nil
+}
+
+// deviceNameByNumber returns the kernel name of the block device with the
+// given major:minor number
+func deviceNameByNumber(majorMinor string) (string, error) {
+	p, err := filepath.EvalSymlinks(filepath.Join(SysfsRoot, "dev", "block", majorMinor))
+	if err != nil {
+		if sysErr := checkSysfs(); sysErr != nil {
+			return "", sysErr
+		}
+		return "", fmt.Errorf("no block device %s: %v", majorMinor, err)
+	}
+	return filepath.Base(p), nil
+}
+
+func isPartition(sysPath string) bool {
+	_, err := os.Stat(filepath.Join(sysPath, "partition"))
+	return err == nil
+}
+
+// ParentDisk resolves a partition such as sdb2 or nvme0n1p2 to the whole
+// disk it lives on. Whole disks are returned unchanged.
+func ParentDisk(devName string) (string, error) {
+	p, err := sysfsBlockPath(devName)
+	if err != nil {
+		return "", err
+	}
+	if !isPartition(p) {
+		return blockName(devName), nil
+	}
+	// partitions are laid out under their disk, e.g. .../block/sdb/sdb2
+	return filepath.Base(filepath.Dir(p)), nil
+}
+
+func readSysfsUint(path string) (uint64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// readQueueUint reads a queue attribute of devName, which for partitions
+// is only exposed on the parent disk
+func readQueueUint(devName, attr string) (uint64, error) {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return 0, err
+	}
+	return readSysfsUint(filepath.Join(sysClassBlock(), disk, "queue", attr))
+}
+
+// SectorType returns the logical and physical sector sizes of devName and
+// whether it is a native 4K sector (4Kn) drive
+func SectorType(devName string) (logical, physical uint64, is4Kn bool, err error) {
+	if logical, err = readQueueUint(devName, "logical_block_size"); err != nil {
+		return 0, 0, false, err
+	}
+	if physical, err = readQueueUint(devName, "physical_block_size"); err != nil {
+		return 0, 0, false, err
+	}
+	return logical, physical, logical == 4096 && physical == 4096, nil
+}
+
+// IsRotational reports whether devName is backed by spinning media
+func IsRotational(devName string) (bool, error) {
+	v, err := readQueueUint(devName, "rotational")
+	if err != nil {
+		return false, err
+	}
+	return v == 1, nil
+}
+
+const (
+	ZoneModelNone        = "none"
+	ZoneModelHostAware   = "host-aware"
+	ZoneModelHostManaged = "host-managed"
+)
+
+// IsZoned reports whether devName is a zoned block device, such as an SMR
+// drive or an NVMe ZNS namespace, along with its zone model. Kernels
+// without zoned block device support have no queue/zoned attribute and
+// are reported as not zoned.
+func IsZoned(devName string) (bool, string, error) {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return false, "", err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(sysClassBlock(), disk, "queue", "zoned"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, ZoneModelNone, nil
+		}
+		return false, "", err
+	}
+	model := strings.TrimSpace(string(b))
+	return model != ZoneModelNone, model, nil
+}
+
+// IsRemovable reports whether devName is removable media or sits on a
+// hotpluggable bus such as USB
+func IsRemovable(devName string) (bool, error) {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return false, err
+	}
+	removable, err := readSysfsUint(filepath.Join(sysClassBlock(), disk, "removable"))
+	if err != nil {
+		return false, err
+	}
+	if removable == 1 {
+		return true, nil
+	}
+
+	// USB disks usually report removable as 0, so check the bus as well
+	p, err := sysfsBlockPath(disk)
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(p, "/usb"), nil
+}
+
+const (
+	TransportUSB     = "usb"
+	TransportNVMe    = "nvme"
+	TransportVirtio  = "virtio"
+	TransportSATA    = "sata"
+	TransportSAS     = "sas"
+	TransportSCSI    = "scsi"
+	TransportUnknown = "unknown"
+)
+
+// Transport infers the bus devName is attached over from its sysfs device
+// path, e.g. /sys/devices/pci0000:00/0000:00:17.0/ata1/host0/... for SATA
+func Transport(devName string) (string, error) {
+	disk, err := ParentDisk(devName)
+	if err != nil {
+		return "", err
+	}
+	p, err := sysfsBlockPath(disk)
+	if err != nil {
+		return "", err
+	}
+
+	// the order matters: a USB to SATA bridge also has scsi host and
+	// target components below its usb ones
+	switch {
+	case strings.Contains(p, "/usb"):
+		return TransportUSB, nil
+	case strings.Contains(p, "/nvme"):
+		return TransportNVMe, nil
+	case strings.Contains(p, "/virtio"):
+		return TransportVirtio, nil
+	case strings.Contains(p, "/ata"):
+		return TransportSATA, nil
+	case strings.Contains(p, "/end_device-"), strings.Contains(p, "/expander-"):
+		return TransportSAS, nil
+	case strings.Contains(p, "/target"):
+		return TransportSCSI, nil
+	}
+	return TransportUnknown, nil
+}
+
+// ReadDeviceSize returns the size of devName in bytes as reported by
+// sysfs, which always counts in 512 byte sectors
+func ReadDeviceSize(devName string) (uint64, error) {
+	sectors, err := readSysfsUint(filepath.Join(sysClassBlock(), blockName(devName), "size"))
+	if err != nil {
+		if sysErr := checkSysfs(); sysErr != nil {
+			return 0, sysErr
+		}
+		return 0, err
+	}
+	return sectors * 512, nil
+}
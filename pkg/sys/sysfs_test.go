@@ -0,0 +1,98 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSysfs points SysfsRoot at a directory holding files, and symlinks
+// for links, each given relative to it. DevRoot is pointed at an empty
+// directory, so device names resolve to themselves.
+func fakeSysfs(t *testing.T, files map[string]string, links map[string]string) string {
+	dir, err := ioutil.TempDir("", "sys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSysfsRoot := SysfsRoot
+	SysfsRoot = dir
+	t.Cleanup(func() {
+		SysfsRoot = oldSysfsRoot
+		os.RemoveAll(dir)
+	})
+	fakeDevRoot(t, nil, nil)
+
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range links {
+		p := filepath.Join(dir, link)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSysfsUnavailable(t *testing.T) {
+	oldSysfsRoot := SysfsRoot
+	SysfsRoot = "/nonexistent/sys"
+	defer func() { SysfsRoot = oldSysfsRoot }()
+
+	testCases := []struct {
+		name  string
+		probe func() error
+	}{
+		{"ParentDisk", func() error { _, err := ParentDisk("sda1"); return err }},
+		{"ReadDeviceSize", func() error { _, err := ReadDeviceSize("sda"); return err }},
+		{"SectorType", func() error { _, _, _, err := SectorType("sda"); return err }},
+		{"IsRotational", func() error { _, err := IsRotational("sda"); return err }},
+		{"IsZoned", func() error { _, _, err := IsZoned("sda"); return err }},
+		{"IsRemovable", func() error { _, err := IsRemovable("sda"); return err }},
+		{"Transport", func() error { _, err := Transport("sda"); return err }},
+		{"ReadDriveGeometry", func() error { _, err := ReadDriveGeometry("sda"); return err }},
+		{"ReadDriveAttributes", func() error { _, err := ReadDriveAttributes("sda"); return err }},
+		{"VolumeIOStats", func() error { _, err := VolumeIOStats("sda"); return err }},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if err := testCase.probe(); !errors.Is(err, ErrSysfsUnavailable) {
+				t.Errorf("expected ErrSysfsUnavailable, got %v", err)
+			}
+		})
+	}
+
+	// probes reading the device itself do not need sysfs
+	if fsType, err := ProbeFSTypeReaderAt(bytes.NewReader(btrfsFixture())); err != nil || fsType != FSTypeBtrfs {
+		t.Errorf("expected btrfs without sysfs, got %q, %v", fsType, err)
+	}
+}
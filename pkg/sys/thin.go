@@ -0,0 +1,150 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// dm-thin metadata blocks are always 4KiB
+const thinMetadataBlockSize = 4 * KiB
+
+var ErrNotThin = errors.New("not a thin provisioned device")
+
+type PoolUsage struct {
+	// Pool is the device mapper name of the thin pool
+	Pool          string
+	DataUsed      uint64
+	DataTotal     uint64
+	MetadataUsed  uint64
+	MetadataTotal uint64
+	// Mode is rw, ro or out_of_data_space
+	Mode string
+}
+
+func dmName(devName string) (string, error) {
+	p, err := sysfsBlockPath(devName)
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(p, "dm", "name"))
+	if err != nil {
+		return "", ErrNotThin
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// dmsetup returns the fields of the first target line printed by
+// dmsetup for the device mapper device name
+func dmsetup(cmd, name string) ([]string, error) {
+	out, stderr, err := run(context.Background(), "dmsetup", cmd, name)
+	if err != nil {
+		return nil, fmt.Errorf("dmsetup %s %s failed: %v: %s", cmd, name, err, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	return strings.Fields(lines[0]), nil
+}
+
+func parseUsedTotal(field string) (uint64, uint64, error) {
+	parts := strings.SplitN(field, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("malformed usage %q", field)
+	}
+	used, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	total, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return used, total, nil
+}
+
+// BackingPoolUsage reports the usage of the thin pool backing devName,
+// which is what runs out first when thin volumes are overcommitted.
+// ErrNotThin is returned for anything but a dm-thin volume.
+func BackingPoolUsage(devName string) (*PoolUsage, error) {
+	name, err := dmName(devName)
+	if err != nil {
+		return nil, err
+	}
+
+	// <start> <length> thin <pool dev> <dev id>
+	table, err := dmsetup("table", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(table) < 4 || table[2] != "thin" {
+		return nil, ErrNotThin
+	}
+	poolDev, err := deviceNameByNumber(table[3])
+	if err != nil {
+		return nil, err
+	}
+	poolName, err := dmName(poolDev)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve thin pool %s of %s: %v", table[3], devName, err)
+	}
+
+	// <start> <length> thin-pool <metadata dev> <data dev> <data block size> ...
+	poolTable, err := dmsetup("table", poolName)
+	if err != nil {
+		return nil, err
+	}
+	if len(poolTable) < 6 || poolTable[2] != "thin-pool" {
+		return nil, fmt.Errorf("unexpected table for thin pool %s: %v", poolName, poolTable)
+	}
+	dataBlockSectors, err := strconv.ParseUint(poolTable[5], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	// <start> <length> thin-pool <transaction id> <used>/<total metadata>
+	// <used>/<total data> <held root> <mode> ...
+	status, err := dmsetup("status", poolName)
+	if err != nil {
+		return nil, err
+	}
+	if len(status) < 8 || status[2] != "thin-pool" {
+		return nil, fmt.Errorf("unexpected status for thin pool %s: %v", poolName, status)
+	}
+	metaUsed, metaTotal, err := parseUsedTotal(status[4])
+	if err != nil {
+		return nil, err
+	}
+	dataUsed, dataTotal, err := parseUsedTotal(status[5])
+	if err != nil {
+		return nil, err
+	}
+
+	dataBlockSize := dataBlockSectors * 512
+	return &PoolUsage{
+		Pool:          poolName,
+		DataUsed:      dataUsed * dataBlockSize,
+		DataTotal:     dataTotal * dataBlockSize,
+		MetadataUsed:  metaUsed * thinMetadataBlockSize,
+		MetadataTotal: metaTotal * thinMetadataBlockSize,
+		Mode:          status[7],
+	}, nil
+}
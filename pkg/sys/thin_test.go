@@ -0,0 +1,88 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBackingPoolUsage(t *testing.T) {
+	fakeSysfs(t,
+		map[string]string{
+			"devices/virtual/block/dm-0/dm/name": "vg0-pool-tpool\n",
+			"devices/virtual/block/dm-1/dm/name": "vg0-thin\n",
+			"devices/virtual/block/dm-2/dm/name": "vg0-linear\n",
+		},
+		map[string]string{
+			"class/block/dm-0": "../../devices/virtual/block/dm-0",
+			"class/block/dm-1": "../../devices/virtual/block/dm-1",
+			"class/block/dm-2": "../../devices/virtual/block/dm-2",
+			"dev/block/253:0":  "../../devices/virtual/block/dm-0",
+		})
+	fake := withFakeRunner(t)
+	fake.Outputs["dmsetup table vg0-thin"] = FakeOutput{Stdout: []byte("0 209715200 thin 253:0 1\n")}
+	fake.Outputs["dmsetup table vg0-pool-tpool"] = FakeOutput{Stdout: []byte("0 419430400 thin-pool 253:3 253:4 128 0 0\n")}
+	fake.Outputs["dmsetup status vg0-pool-tpool"] = FakeOutput{Stdout: []byte("0 419430400 thin-pool 5 213/4096 1600/3200 - rw discard_passdown queue_if_no_space - 1024\n")}
+	fake.Outputs["dmsetup table vg0-linear"] = FakeOutput{Stdout: []byte("0 2097152 linear 8:16 2048\n")}
+
+	usage, err := BackingPoolUsage("dm-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &PoolUsage{
+		Pool:          "vg0-pool-tpool",
+		DataUsed:      1600 * 128 * 512,
+		DataTotal:     3200 * 128 * 512,
+		MetadataUsed:  213 * 4096,
+		MetadataTotal: 4096 * 4096,
+		Mode:          "rw",
+	}
+	if !reflect.DeepEqual(usage, expected) {
+		t.Errorf("expected %+v, got %+v", expected, usage)
+	}
+
+	if _, err := BackingPoolUsage("dm-2"); !errors.Is(err, ErrNotThin) {
+		t.Errorf("expected ErrNotThin for a linear volume, got %v", err)
+	}
+}
+
+func TestParseUsedTotal(t *testing.T) {
+	testCases := []struct {
+		field string
+		used  uint64
+		total uint64
+		fail  bool
+	}{
+		{field: "213/4096", used: 213, total: 4096},
+		{field: "0/0", used: 0, total: 0},
+		{field: "213", fail: true},
+		{field: "a/4096", fail: true},
+		{field: "213/-", fail: true},
+	}
+	for _, testCase := range testCases {
+		used, total, err := parseUsedTotal(testCase.field)
+		if fail := err != nil; fail != testCase.fail {
+			t.Errorf("%s: expected failure %v, got %v", testCase.field, testCase.fail, err)
+			continue
+		}
+		if used != testCase.used || total != testCase.total {
+			t.Errorf("%s: expected %d/%d, got %d/%d", testCase.field, testCase.used, testCase.total, used, total)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+	"unsafe"
+
+	"github.com/golang/glog"
+	"golang.org/x/sys/unix"
+)
+
+// struct fstrim_range
+type fstrimRange struct {
+	start  uint64
+	len    uint64
+	minLen uint64
+}
+
+var fitrim = iocIOWR('X', 121, unsafe.Sizeof(fstrimRange{}))
+
+// ErrTrimUnsupported matches the error returned when a filesystem cannot
+// be trimmed, whatever its type
+var ErrTrimUnsupported = &ErrUnsupportedOperation{Operation: "trim"}
+
+// TrimFilesystem discards all unused blocks of the filesystem mounted at
+// mountpoint and returns the number of bytes trimmed
+func TrimFilesystem(mountpoint string) (uint64, error) {
+	d, err := os.Open(mountpoint)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+
+	r := &fstrimRange{
+		len: math.MaxUint64,
+	}
+	if err := ioctl(d.Fd(), fitrim, unsafe.Pointer(r)); err != nil {
+		if err == unix.EOPNOTSUPP || err == unix.ENOTTY {
+			return 0, fmt.Errorf("could not trim %s: %w", mountpoint, unsupported(fsTypeOf(d), "trim"))
+		}
+		return 0, fmt.Errorf("could not trim %s: %v", mountpoint, err)
+	}
+	// the kernel updates len with the number of bytes trimmed
+	return r.len, nil
+}
+
+// SupportsTrim reports whether the filesystem mounted at mountpoint can
+// be trimmed, without trimming anything. Filesystems check for discard
+// support before validating the range, so an empty range fails with
+// EOPNOTSUPP when trimming is unsupported and EINVAL otherwise.
+func SupportsTrim(mountpoint string) (bool, error) {
+	d, err := os.Open(mountpoint)
+	if err != nil {
+		return false, err
+	}
+	defer d.Close()
+
+	r := &fstrimRange{}
+	switch err := ioctl(d.Fd(), fitrim, unsafe.Pointer(r)); err {
+	case nil, unix.EINVAL:
+		return true, nil
+	case unix.EOPNOTSUPP, unix.ENOTTY:
+		return false, nil
+	default:
+		return false, fmt.Errorf("could not probe trim support of %s: %v", mountpoint, err)
+	}
+}
+
+// ScheduleTrim trims mountpoint every interval until ctx is cancelled or
+// the filesystem turns out not to support trimming. Each run is passed
+// to report, which may be nil.
+func ScheduleTrim(ctx context.Context, mountpoint string, interval time.Duration, report func(mountpoint string, trimmed uint64, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		trimmed, err := TrimFilesystem(mountpoint)
+		if err != nil {
+			glog.Errorf("trim of %s failed: %v", mountpoint, err)
+		} else {
+			glog.V(5).Infof("trimmed %d bytes on %s", trimmed, mountpoint)
+		}
+		if report != nil {
+			report(mountpoint, trimmed, err)
+		}
+		if errors.Is(err, ErrTrimUnsupported) {
+			return
+		}
+	}
+}
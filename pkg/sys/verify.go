@@ -0,0 +1,156 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+func walkTree(root string) (map[string]os.FileInfo, error) {
+	entries := map[string]os.FileInfo{}
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		entries[rel] = info
+		return nil
+	})
+	return entries, err
+}
+
+func fileChecksum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// VerifyTree compares the trees at srcPath and dstPath by file type,
+// mode, size, symlink target and content checksum. It returns whether
+// they match along with the relative paths that differ. Files are hashed
+// in parallel, one worker per CPU.
+func VerifyTree(srcPath, dstPath string) (bool, []string, error) {
+	src, err := walkTree(srcPath)
+	if err != nil {
+		return false, nil, err
+	}
+	dst, err := walkTree(dstPath)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var (
+		mismatchLock sync.Mutex
+		mismatched   []string
+		firstErr     error
+	)
+	mismatch := func(rel string, err error) {
+		mismatchLock.Lock()
+		defer mismatchLock.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		mismatched = append(mismatched, rel)
+	}
+
+	for rel := range dst {
+		if _, ok := src[rel]; !ok {
+			mismatch(rel, nil)
+		}
+	}
+
+	toHash := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range toHash {
+				srcSum, err := fileChecksum(filepath.Join(srcPath, rel))
+				if err != nil {
+					mismatch(rel, err)
+					continue
+				}
+				dstSum, err := fileChecksum(filepath.Join(dstPath, rel))
+				if err != nil {
+					mismatch(rel, err)
+					continue
+				}
+				if !bytes.Equal(srcSum, dstSum) {
+					mismatch(rel, nil)
+				}
+			}
+		}()
+	}
+
+	for rel, s := range src {
+		d, ok := dst[rel]
+		switch {
+		case !ok, s.Mode() != d.Mode():
+			mismatch(rel, nil)
+		case s.Mode()&os.ModeSymlink != 0:
+			sTarget, err := os.Readlink(filepath.Join(srcPath, rel))
+			if err != nil {
+				mismatch(rel, err)
+				continue
+			}
+			dTarget, err := os.Readlink(filepath.Join(dstPath, rel))
+			if err != nil {
+				mismatch(rel, err)
+				continue
+			}
+			if sTarget != dTarget {
+				mismatch(rel, nil)
+			}
+		case s.Mode().IsRegular():
+			if s.Size() != d.Size() {
+				mismatch(rel, nil)
+				continue
+			}
+			toHash <- rel
+		}
+	}
+	close(toHash)
+	wg.Wait()
+
+	if firstErr != nil {
+		return false, nil, firstErr
+	}
+	sort.Strings(mismatched)
+	return len(mismatched) == 0, mismatched, nil
+}
@@ -0,0 +1,56 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"strings"
+)
+
+var wwnPrefixes = []string{"0x", "naa.", "eui.", "nvme."}
+
+// NormalizeWWN converts a SCSI WWN or an NVMe EUI64/NGUID into a canonical
+// form, so that the same drive is identified alike whichever transport it
+// is seen on. IDs that are not plain hex, such as t10 vendor IDs, are only
+// trimmed and lowercased.
+func NormalizeWWN(raw string) string {
+	id := strings.ToLower(strings.TrimSpace(raw))
+	for _, prefix := range wwnPrefixes {
+		if strings.HasPrefix(id, prefix) {
+			id = strings.TrimPrefix(id, prefix)
+			break
+		}
+	}
+	id = strings.NewReplacer("-", "", ":", "", " ", "").Replace(id)
+
+	for _, c := range id {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return id
+		}
+	}
+
+	// NAA 5 and EUI64 IDs are 16 digits long while NAA 6 and NGUID are 32;
+	// sources that print them as numbers drop the leading zeros
+	switch {
+	case len(id) == 0:
+		return id
+	case len(id) <= 16:
+		return strings.Repeat("0", 16-len(id)) + id
+	case len(id) <= 32:
+		return strings.Repeat("0", 32-len(id)) + id
+	}
+	return id
+}
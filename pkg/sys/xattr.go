@@ -0,0 +1,114 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	xattrUserPrefix     = "user."
+	xattrSecurityPrefix = "security."
+)
+
+// ErrXattrsUnsupported is returned when the destination filesystem does
+// not support extended attributes. Callers copying data may treat it as a
+// warning.
+var ErrXattrsUnsupported = errors.New("extended attributes not supported")
+
+func listXattrs(path string) ([]string, error) {
+	size, err := unix.Llistxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) != 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	size, err := unix.Lgetxattr(path, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	size, err = unix.Lgetxattr(path, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
+
+func copyXattrs(src, dst string, prefixes ...string) error {
+	names, err := listXattrs(src)
+	if err != nil {
+		if err == unix.ENOTSUP {
+			return nil
+		}
+		return fmt.Errorf("could not list xattrs of %s: %v", src, err)
+	}
+
+	for _, name := range names {
+		copied := false
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(name, prefix) {
+				copied = true
+				break
+			}
+		}
+		if !copied {
+			continue
+		}
+
+		value, err := getXattr(src, name)
+		if err != nil {
+			return fmt.Errorf("could not read xattr %s of %s: %v", name, src, err)
+		}
+		if err := unix.Lsetxattr(dst, name, value, 0); err != nil {
+			if err == unix.ENOTSUP {
+				return fmt.Errorf("%w: %s", ErrXattrsUnsupported, dst)
+			}
+			return fmt.Errorf("could not set xattr %s on %s: %v", name, dst, err)
+		}
+	}
+	return nil
+}
+
+// CopyXattrs copies the user.* extended attributes of src to dst
+func CopyXattrs(src, dst string) error {
+	return copyXattrs(src, dst, xattrUserPrefix)
+}
+
+// CopySecurityXattrs copies both the user.* and the security.* extended
+// attributes of src to dst. Setting security.* needs CAP_SYS_ADMIN.
+func CopySecurityXattrs(src, dst string) error {
+	return copyXattrs(src, dst, xattrUserPrefix, xattrSecurityPrefix)
+}
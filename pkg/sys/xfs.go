@@ -0,0 +1,247 @@
+// This file is part of MinIO Direct CSI
+// Copyright (c) 2020 MinIO, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sys
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	xfsSBMagic  = 0x58465342 // XFSB
+	xfsAGFMagic = 0x58414746 // XAGF
+)
+
+// leading part of the on-disk struct xfs_dsb, all fields big endian
+type xfsSuperblock struct {
+	MagicNum   uint32
+	BlockSize  uint32
+	DBlocks    uint64
+	RBlocks    uint64
+	RExtents   uint64
+	UUID       [16]byte
+	LogStart   uint64
+	RootIno    uint64
+	RBMIno     uint64
+	RSumIno    uint64
+	RExtSize   uint32
+	AGBlocks   uint32
+	AGCount    uint32
+	RBMBlocks  uint32
+	LogBlocks  uint32
+	VersionNum uint16
+	SectSize   uint16
+	InodeSize  uint16
+	InoPBlock  uint16
+	FName      [12]byte
+	BlockLog   uint8
+	SectLog    uint8
+	InodeLog   uint8
+	InoPBLog   uint8
+	AGBlkLog   uint8
+	RExtSLog   uint8
+	InProgress uint8
+	IMaxPct    uint8
+	ICount     uint64
+	IFree      uint64
+	FDBlocks   uint64
+	FRExtents  uint64
+	UQuotIno   uint64
+	GQuotIno   uint64
+	QFlags     uint16
+	Flags      uint8
+	SharedVN   uint8
+	InoAlignMT uint32
+	Unit       uint32
+	Width      uint32
+	DirBlkLog  uint8
+	LogSectLog uint8
+	LogSectSz  uint16
+	LogSUnit   uint32
+	Features2  uint32
+	BadFeat2   uint32
+	// version 5 superblocks only
+	FeatCompat      uint32
+	FeatROCompat    uint32
+	FeatIncompat    uint32
+	FeatLogIncompat uint32
+}
+
+const (
+	xfsSBVersionMask = 0xf
+	xfsSBVersion5    = 5
+
+	xfsSBFeatIncompatNeedsRepair = 1 << 4
+)
+
+// needsRepair reports whether xfs_repair has to be run before the
+// filesystem may be mounted, which it flags while repairs are underway
+func (sb *xfsSuperblock) needsRepair() bool {
+	return sb.VersionNum&xfsSBVersionMask == xfsSBVersion5 &&
+		sb.FeatIncompat&xfsSBFeatIncompatNeedsRepair != 0
+}
+
+// leading part of the on-disk struct xfs_agf
+type xfsAGF struct {
+	MagicNum   uint32
+	VersionNum uint32
+	SeqNo      uint32
+	Length     uint32
+	Roots      [3]uint32
+	Levels     [3]uint32
+	FLFirst    uint32
+	FLLast     uint32
+	FLCount    uint32
+	FreeBlks   uint32
+	Longest    uint32
+}
+
+func readXFSSuperblock(r io.ReaderAt) (*xfsSuperblock, error) {
+	sb := &xfsSuperblock{}
+	if err := binary.Read(io.NewSectionReader(r, 0, int64(binary.Size(sb))), binary.BigEndian, sb); err != nil {
+		return nil, err
+	}
+	if sb.MagicNum != xfsSBMagic {
+		return nil, fmt.Errorf("no xfs superblock found")
+	}
+	if sb.BlockSize == 0 || sb.AGBlocks == 0 || sb.SectSize == 0 {
+		return nil, fmt.Errorf("corrupt xfs superblock")
+	}
+	return sb, nil
+}
+
+type AGFreeSpace struct {
+	AGNumber uint32
+	// BlockSize is the filesystem block size the counts below are in
+	BlockSize uint32
+	// FreeBlocks includes the blocks on the AG free list
+	FreeBlocks uint64
+	// LongestFree is the longest contiguous free extent
+	LongestFree uint64
+}
+
+// ReadXFSAGFreeSpace reads the free space of every allocation group of
+// the XFS filesystem on devName from their AGF headers. The result has
+// one entry per AG.
+func ReadXFSAGFreeSpace(devName string) ([]AGFreeSpace, error) {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sb, err := readXFSSuperblock(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", devName, err)
+	}
+
+	ags := make([]AGFreeSpace, 0, sb.AGCount)
+	for agno := uint32(0); agno < sb.AGCount; agno++ {
+		// the AGF lives in the second sector of each AG
+		offset := int64(agno)*int64(sb.AGBlocks)*int64(sb.BlockSize) + int64(sb.SectSize)
+		agf := xfsAGF{}
+		if err := binary.Read(io.NewSectionReader(f, offset, int64(binary.Size(agf))), binary.BigEndian, &agf); err != nil {
+			return nil, fmt.Errorf("could not read AGF %d of %s: %v", agno, devName, err)
+		}
+		if agf.MagicNum != xfsAGFMagic || agf.SeqNo != agno {
+			return nil, fmt.Errorf("corrupt AGF %d on %s", agno, devName)
+		}
+		ags = append(ags, AGFreeSpace{
+			AGNumber:    agno,
+			BlockSize:   sb.BlockSize,
+			FreeBlocks:  uint64(agf.FreeBlks) + uint64(agf.FLCount),
+			LongestFree: uint64(agf.Longest),
+		})
+	}
+	return ags, nil
+}
+
+// XFSNeedsRepair reports whether the XFS filesystem on devName carries the
+// needsrepair incompat flag, in which case the kernel refuses to mount it
+// until xfs_repair has completed.
+//
+// Whether the log is dirty is not reported: that needs the log head and
+// tail, which are only found by scanning the log itself.
+func XFSNeedsRepair(devName string) (bool, error) {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	sb, err := readXFSSuperblock(f)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", devName, err)
+	}
+	return sb.needsRepair(), nil
+}
+
+var ErrStripeMismatch = errors.New("filesystem stripe geometry does not match device")
+
+// XFSStripe is the stripe geometry an XFS filesystem was created with, in
+// bytes; both are zero when it was created without one
+type XFSStripe struct {
+	Unit  uint64
+	Width uint64
+}
+
+// ReadXFSStripe reads the stripe unit and width from the superblock of the
+// XFS filesystem on devName
+func ReadXFSStripe(devName string) (*XFSStripe, error) {
+	f, err := openBlockFile(devName, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sb, err := readXFSSuperblock(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", devName, err)
+	}
+	// sb_unit and sb_width are kept in filesystem blocks
+	return &XFSStripe{
+		Unit:  uint64(sb.Unit) * uint64(sb.BlockSize),
+		Width: uint64(sb.Width) * uint64(sb.BlockSize),
+	}, nil
+}
+
+// ValidateXFSStripe fails with ErrStripeMismatch if the XFS filesystem on
+// devName was created with a stripe unit or width other than the chunk
+// and stripe size the device reports in sysfs. Devices not reporting a
+// stripe, such as many hardware RAID controllers, are not checked, since
+// their stripe can only have been given by hand.
+func ValidateXFSStripe(devName string) error {
+	g, err := ReadDriveGeometry(devName)
+	if err != nil {
+		return err
+	}
+	if !g.striped() {
+		return nil
+	}
+	stripe, err := ReadXFSStripe(devName)
+	if err != nil {
+		return err
+	}
+	if stripe.Unit != g.MinimumIO || stripe.Width != g.OptimalIO {
+		return fmt.Errorf("%w: %s has sunit=%d swidth=%d bytes, device reports chunk %d and stripe %d bytes",
+			ErrStripeMismatch, devName, stripe.Unit, stripe.Width, g.MinimumIO, g.OptimalIO)
+	}
+	return nil
+}
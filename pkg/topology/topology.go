@@ -22,8 +22,35 @@ const (
 	TopologyDriverRack     = "direct.csi.min.io/rack"
 	TopologyDriverZone     = "direct.csi.min.io/zone"
 	TopologyDriverRegion   = "direct.csi.min.io/region"
+	TopologyDriverMedia    = "direct.csi.min.io/media"
 )
 
+const (
+	MediaSSD = "ssd"
+	MediaHDD = "hdd"
+)
+
+// RackMapper returns the rack a node is racked in, or "" if unknown
+type RackMapper func(node string) string
+
+// TopologyKeys returns the topology segments advertised for a drive on
+// node. The rack segment is only set when rackFor knows the node.
+func TopologyKeys(node string, rotational bool, rackFor RackMapper) map[string]string {
+	keys := map[string]string{
+		TopologyDriverNode:  node,
+		TopologyDriverMedia: MediaSSD,
+	}
+	if rotational {
+		keys[TopologyDriverMedia] = MediaHDD
+	}
+	if rackFor != nil {
+		if rack := rackFor(node); rack != "" {
+			keys[TopologyDriverRack] = rack
+		}
+	}
+	return keys
+}
+
 type TopologyConstraint struct {
 	DriverIdentity string `json:"driverIdentity,omitempty"`
 	DriverNode     string `json:"driverNode,omitempty"`